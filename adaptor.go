@@ -0,0 +1,121 @@
+package web
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// WrapHandler 在 Ctx 中运行 net/http handler
+// the response is buffered, streaming and hijacking are not supported.
+//
+//  app.Use("/debug/pprof", web.WrapHandler(http.DefaultServeMux))
+func WrapHandler(h http.Handler) func(*Ctx) {
+	handler := fasthttpadaptor.NewFastHTTPHandler(h)
+	return func(c *Ctx) {
+		handler(c.RequestCtx)
+	}
+}
+
+// WrapMiddleware 使用 net/http 中间件
+// the chain continues with Ctx.Next when the middleware calls next,
+// headers it sets on the request are copied, the request context is not.
+// mw wraps a new next handler on every request.
+//
+//  app.Use(web.WrapMiddleware(cors.Default().Handler))
+func WrapMiddleware(mw func(http.Handler) http.Handler) func(*Ctx) {
+	return func(c *Ctx) {
+		called := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			for k, vv := range r.Header {
+				for i, v := range vv {
+					if i == 0 {
+						c.Request.Header.Set(k, v)
+					} else {
+						c.Request.Header.Add(k, v)
+					}
+				}
+			}
+		})
+		fasthttpadaptor.NewFastHTTPHandler(mw(next))(c.RequestCtx)
+		if called {
+			c.Next()
+		}
+	}
+}
+
+// mountHandler route all methods under prefix to h,
+// the prefix is stripped from the path h sees.
+func (c *Core) mountHandler(prefix string, h http.Handler) {
+	// a mounted app sees the full path, its mount prefix is known at request time
+	handler := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = r.URL.Path[len(joinPath(c.mountPath(), prefix)):]
+		if r.URL.Path == "" || r.URL.Path[0] != '/' {
+			r.URL.Path = "/" + r.URL.Path
+		}
+		r.URL.RawPath = ""
+		h.ServeHTTP(w, r)
+	}))
+	c.pushMethod("USE", prefix, func(ctx *Ctx) {
+		// the middleware prefix matches /metricsfoo as well
+		lower := c.routePath(joinPath(c.mountPath(), prefix))
+		if lower != "/" && ctx.path != lower && !strings.HasPrefix(ctx.path, lower+"/") {
+			ctx.Next()
+			return
+		}
+		handler(ctx)
+	})
+	c.last[len(c.last)-1].handler = "Mount(" + funcName(h) + ")"
+}
+
+// HTTPHandler 以 http.Handler 提供服务
+// for httptest and net/http servers, the response is buffered
+// and hijacking (websocket) is not supported.
+func (c *Core) HTTPHandler() http.Handler {
+	if c.Server == nil {
+		if err := c.Build(); err != nil {
+			panic(err)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		req.Header.SetMethod(r.Method)
+		req.SetRequestURI(r.URL.RequestURI())
+		req.Header.SetHost(r.Host)
+		for k, vv := range r.Header {
+			for _, v := range vv {
+				req.Header.Add(k, v)
+			}
+		}
+		if r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.SetBody(body)
+		}
+
+		var addr net.Addr
+		if a, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+			addr = a
+		}
+		var fctx fasthttp.RequestCtx
+		fctx.Init(req, addr, nil)
+		c.handler(&fctx)
+
+		fctx.Response.Header.VisitAll(func(k, v []byte) {
+			w.Header().Add(string(k), string(v))
+		})
+		w.WriteHeader(fctx.Response.StatusCode())
+		if r.Method != MethodHead {
+			fctx.Response.BodyWriteTo(w)
+		}
+	})
+}
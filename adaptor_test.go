@@ -0,0 +1,125 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMountHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("h:" + r.URL.Path))
+	})
+	sub := New()
+	sub.Mount("/h", h)
+	app := New()
+	app.Mount("/m", h)
+	app.Mount("/shop", sub)
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		code int
+		body string
+	}{
+		{"/m/abc", 200, "h:/abc"},
+		{"/m", 200, "h:/"},
+		{"/shop/h/abc", 200, "h:/abc"},
+		{"/shop/h", 200, "h:/"},
+		{"/shop/hx", 404, ""},
+		{"/h/abc", 404, ""},
+	}
+	for _, tc := range cases {
+		resp := serve(app, MethodGet, tc.uri)
+		if resp.StatusCode() != tc.code || tc.body != "" && string(resp.Body()) != tc.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", tc.uri, resp.StatusCode(), resp.Body(), tc.code, tc.body)
+		}
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	app := New()
+	app.Post("/echo/:id", func(c *Ctx) {
+		c.Set("X-Id", c.Params("id"))
+		c.Set("X-Token", c.Get("X-Token"))
+		c.Response.SetStatusCode(201)
+		c.Send(c.Body())
+	})
+	app.Get("/page", func(c *Ctx) {
+		c.Set(HeaderContentType, MIMETextPlain)
+		c.Send("page body")
+	})
+	srv := httptest.NewServer(app.HTTPHandler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(MethodPost, srv.URL+"/echo/7?q=1", strings.NewReader("hello body"))
+	req.Header.Set("X-Token", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 201 || string(body) != "hello body" || resp.Header.Get("X-Id") != "7" || resp.Header.Get("X-Token") != "secret" {
+		t.Errorf("POST: got %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	resp, err = http.Head(srv.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || len(body) != 0 || resp.Header.Get(HeaderContentType) != MIMETextPlain {
+		t.Errorf("HEAD: got %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	resp, err = http.Get(srv.URL + "/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 404 {
+		t.Errorf("GET /nope: got %d", resp.StatusCode)
+	}
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	// sets a request header and calls next, or answers 401 without calling it
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", "Basic")
+				http.Error(w, "denied", http.StatusUnauthorized)
+				return
+			}
+			r.Header.Set("X-User", "joe")
+			r.Header.Add("X-Role", "a")
+			r.Header.Add("X-Role", "b")
+			next.ServeHTTP(w, r)
+		})
+	}
+	app := New()
+	app.Use(WrapMiddleware(auth))
+	app.Get("/me", func(c *Ctx) {
+		var roles []string
+		c.Request.Header.VisitAll(func(k, v []byte) {
+			if string(k) == "X-Role" {
+				roles = append(roles, string(v))
+			}
+		})
+		c.Send(c.Get("X-User") + " " + strings.Join(roles, ","))
+	})
+	app.Build()
+
+	resp := serve(app, MethodGet, "/me")
+	if resp.StatusCode() != 401 || !strings.Contains(string(resp.Body()), "denied") || string(resp.Header.Peek("WWW-Authenticate")) != "Basic" {
+		t.Errorf("short-circuit: got %d %q", resp.StatusCode(), resp.Body())
+	}
+	resp = serveRequest(app, newRequest(MethodGet, "/me", "Authorization", "Basic x"))
+	if resp.StatusCode() != 200 || string(resp.Body()) != "joe a,b" {
+		t.Errorf("next: got %d %q", resp.StatusCode(), resp.Body())
+	}
+}
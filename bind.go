@@ -0,0 +1,425 @@
+package web
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Bind 按 tag 合并请求数据到结构体并校验
+// bodies with a codec (json, xml, RegisterCodec) decode first, then fields tagged form, query,
+// header and path are set in this order, a later source wins.
+// A decode error is a 400 *Error, a failed validation a *ValidationError (422).
+//
+//  type Req struct {
+//  	ID    int    `path:"id" validate:"required,min=1"`
+//  	Page  int    `query:"page" validate:"max=100"`
+//  	Token string `header:"X-Token" validate:"required"`
+//  	Name  string `json:"name" form:"name" validate:"required"`
+//  	Email string `json:"email" validate:"email"`
+//  	Sort  string `query:"sort" validate:"oneof=asc desc"`
+//  }
+func (c *Ctx) Bind(out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Bind: out must be a pointer to struct, got %T", out)
+	}
+	ctype := getString(c.Request.Header.ContentType())
+	if body := c.Request.Body(); len(body) > 0 && mediaType(ctype) != MIMETextPlain {
+		if codec := c.bodyCodec(ctype); codec != nil {
+			if err := codec.Unmarshal(body, out); err != nil {
+				return NewError(400, err.Error())
+			}
+		}
+	}
+
+	var form func(k string) []string
+	switch {
+	case strings.HasPrefix(ctype, MIMEApplicationForm):
+		form = func(k string) []string { return peekMulti(c.PostArgs().PeekMulti(k)) }
+	case strings.HasPrefix(ctype, MIMEMultipartForm):
+		if mf, err := c.MultipartForm(); err == nil {
+			form = func(k string) []string { return mf.Value[k] }
+		}
+	}
+
+	elem := rv.Elem()
+	fields, err := cachedFields(elem.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		var vals []string
+		if f.form != "" && form != nil {
+			vals = pick(vals, form(f.form))
+		}
+		if f.query != "" {
+			vals = pick(vals, peekMulti(c.QueryArgs().PeekMulti(f.query)))
+		}
+		if f.header != "" {
+			if v := c.Request.Header.Peek(f.header); len(v) > 0 {
+				vals = []string{string(v)}
+			}
+		}
+		if f.path != "" {
+			if v := c.Params(f.path); v != "" {
+				vals = []string{v}
+			}
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		if err := setField(elem.FieldByIndex(f.index), vals); err != nil {
+			return NewError(400, fmt.Sprintf("%s: %v", f.name, err))
+		}
+	}
+	return Validate(out)
+}
+
+func peekMulti(raw [][]byte) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	vals := make([]string, len(raw))
+	for i := range raw {
+		vals[i] = string(raw[i])
+	}
+	return vals
+}
+
+func pick(old, vals []string) []string {
+	if len(vals) > 0 {
+		return vals
+	}
+	return old
+}
+
+// setField convert vals to the field type.
+func setField(v reflect.Value, vals []string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), vals)
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(vals[0]))
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i := range vals {
+			if err := setField(s.Index(i), vals[i:i+1]); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	s := vals[0]
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice: // []byte
+		v.SetBytes([]byte(s))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError 校验失败的所有字段, DefaultErrorHandler 返回 422
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		msgs[i] = e.Errors[i].Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate 按 validate tag 校验结构体
+// rules: required, min=N, max=N (value of numbers, length of strings,
+// slices and maps), email, oneof=a b c.
+// Empty fields without required are not checked, nested structs are.
+// Unknown rules and bad params are an error of the struct type, found when it is
+// first used, handlers taking a request struct check it at registration.
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	ve := new(ValidationError)
+	if err := validateStruct(rv, "", ve); err != nil {
+		return err
+	}
+	if len(ve.Errors) > 0 {
+		return ve
+	}
+	return nil
+}
+
+var emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s.]+$`)
+
+func validateStruct(rv reflect.Value, prefix string, ve *ValidationError) error {
+	fields, err := cachedFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		v := rv.FieldByIndex(f.index)
+		name := prefix + f.name
+		for _, r := range f.rules {
+			if v.IsZero() && !f.required {
+				break
+			}
+			ok, msg, err := checkRule(v, r)
+			if err != nil {
+				return fmt.Errorf("validate %s: %v", name, err)
+			}
+			if !ok {
+				ve.Errors = append(ve.Errors, FieldError{Field: name, Rule: r.name, Param: r.param, Message: name + " " + msg})
+				break
+			}
+		}
+		if f.dive {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					continue
+				}
+				v = v.Elem()
+			}
+			if err := validateStruct(v, name+".", ve); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRule(v reflect.Value, r rule) (ok bool, msg string, err error) {
+	if r.name != "required" {
+		v = reflect.Indirect(v)
+	}
+	switch r.name {
+	case "required":
+		return !v.IsZero(), "is required", nil
+	case "min", "max":
+		limit, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return false, "", fmt.Errorf("%s needs a number", r.name)
+		}
+		var n float64
+		length := true
+		switch v.Kind() {
+		case reflect.String:
+			n = float64(utf8.RuneCountInString(v.String()))
+		case reflect.Slice, reflect.Array, reflect.Map:
+			n = float64(v.Len())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, length = float64(v.Int()), false
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, length = float64(v.Uint()), false
+		case reflect.Float32, reflect.Float64:
+			n, length = v.Float(), false
+		default:
+			return false, "", fmt.Errorf("%s on %s", r.name, v.Type())
+		}
+		word := "at least"
+		if r.name == "max" {
+			ok, word = n <= limit, "at most"
+		} else {
+			ok = n >= limit
+		}
+		if length {
+			return ok, "length must be " + word + " " + r.param, nil
+		}
+		return ok, "must be " + word + " " + r.param, nil
+	case "email":
+		return emailRegexp.MatchString(fmt.Sprint(v.Interface())), "must be a valid email", nil
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, o := range strings.Fields(r.param) {
+			if s == o {
+				return true, "", nil
+			}
+		}
+		return false, "must be one of [" + r.param + "]", nil
+	}
+	return false, "", fmt.Errorf("unknown rule %q", r.name)
+}
+
+type rule struct {
+	name, param string
+}
+
+// check the rule is known and fits a field of type t.
+func (r rule) check(t reflect.Type) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch r.name {
+	case "required", "email":
+	case "min", "max":
+		if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+			return fmt.Errorf("%s needs a number", r.name)
+		}
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return fmt.Errorf("%s on %s", r.name, t)
+		}
+	case "oneof":
+		if strings.TrimSpace(r.param) == "" {
+			return fmt.Errorf("oneof needs values")
+		}
+	default:
+		return fmt.Errorf("unknown rule %q", r.name)
+	}
+	return nil
+}
+
+// bindField a struct field with bind and validate tags.
+type bindField struct {
+	index                     []int
+	name                      string // used in errors
+	path, query, header, form string
+	rules                     []rule
+	required                  bool
+	dive                      bool // validate the nested struct
+}
+
+// bindType fields of a struct type, err is a bad validate tag.
+type bindType struct {
+	fields []bindField
+	err    error
+}
+
+var bindFields sync.Map // reflect.Type -> *bindType
+
+// cachedFields fields of struct t, nested structs are checked too.
+func cachedFields(t reflect.Type) ([]bindField, error) {
+	if bt, ok := bindFields.Load(t); ok {
+		return bt.(*bindType).fields, bt.(*bindType).err
+	}
+	fs, err := structFields(t, nil)
+	bt := &bindType{fields: fs, err: err}
+	bindFields.Store(t, bt) // stored first, a struct may nest itself
+	for _, f := range fs {
+		if !f.dive || bt.err != nil {
+			continue
+		}
+		ft := t.FieldByIndex(f.index).Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if _, err := cachedFields(ft); err != nil {
+			bindFields.Store(t, &bindType{fields: fs, err: err})
+			return fs, err
+		}
+	}
+	return fs, bt.err
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func structFields(t reflect.Type, index []int) (fs []bindField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		idx := append(append([]int(nil), index...), i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			embedded, err := structFields(sf.Type, idx)
+			if err != nil {
+				return nil, err
+			}
+			fs = append(fs, embedded...)
+			continue
+		}
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		f := bindField{
+			index:  idx,
+			path:   sf.Tag.Get("path"),
+			query:  sf.Tag.Get("query"),
+			header: sf.Tag.Get("header"),
+			form:   sf.Tag.Get("form"),
+		}
+		f.name = sf.Name
+		for _, tag := range []string{strings.Split(sf.Tag.Get("json"), ",")[0], f.path, f.query, f.header, f.form} {
+			if tag != "" && tag != "-" {
+				f.name = tag
+				break
+			}
+		}
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, s := range strings.Split(tag, ",") {
+				r := rule{name: s}
+				if i := strings.IndexByte(s, '='); i >= 0 {
+					r = rule{name: s[:i], param: s[i+1:]}
+				}
+				if err := r.check(sf.Type); err != nil {
+					field := sf.Name
+					if t.Name() != "" {
+						field = t.Name() + "." + field
+					}
+					return nil, fmt.Errorf("validate %s: %v", field, err)
+				}
+				f.rules = append(f.rules, r)
+				f.required = f.required || r.name == "required"
+			}
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		f.dive = ft.Kind() == reflect.Struct && !reflect.PtrTo(ft).Implements(textUnmarshalerType)
+		if f.rules == nil && !f.dive && f.path == "" && f.query == "" && f.header == "" && f.form == "" {
+			continue
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
@@ -0,0 +1,241 @@
+package web
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindReq struct {
+	Name  string     `json:"name" form:"name" query:"name" header:"X-Name" path:"name"`
+	Tags  []string   `query:"tag"`
+	IDs   []int      `query:"id"`
+	Limit *int       `query:"limit"`
+	Opt   *string    `query:"opt"`
+	Since time.Time  `query:"since"`
+	Raw   []byte     `header:"X-Raw"`
+	Addr  *bindAddr  `json:"addr"`
+	Flags []bindFlag `query:"flag"`
+}
+
+type bindAddr struct {
+	City string `json:"city" validate:"required"`
+}
+
+// bindFlag a TextUnmarshaler
+type bindFlag string
+
+func (f *bindFlag) UnmarshalText(b []byte) error {
+	*f = bindFlag(strings.ToUpper(string(b)))
+	return nil
+}
+
+func TestBindSources(t *testing.T) {
+	var got bindReq
+	app := New()
+	app.All("/b/:name?", func(c *Ctx) {
+		got = bindReq{}
+		if err := c.Bind(&got); err != nil {
+			c.Next(err)
+			return
+		}
+		c.Send("ok")
+	})
+	app.Build()
+
+	json := func(body string) []string { return []string{HeaderContentType, MIMEApplicationJSON, "body", body} }
+	form := func(body string) []string { return []string{HeaderContentType, MIMEApplicationForm, "body", body} }
+	cases := []struct {
+		uri     string
+		headers []string
+		name    string
+	}{
+		{"/b", json(`{"name":"json"}`), "json"},
+		{"/b?name=query", json(`{"name":"json"}`), "query"},
+		{"/b", form("name=form"), "form"},
+		{"/b?name=query", form("name=form"), "query"},
+		{"/b?name=query", []string{"X-Name", "header"}, "header"},
+		{"/b/path?name=query", []string{"X-Name", "header"}, "path"},
+		{"/b", nil, ""},
+	}
+	for _, tc := range cases {
+		req := newRequest(MethodPost, tc.uri)
+		for i := 0; i+1 < len(tc.headers); i += 2 {
+			if tc.headers[i] == "body" {
+				req.SetBodyString(tc.headers[i+1])
+				continue
+			}
+			req.Header.Set(tc.headers[i], tc.headers[i+1])
+		}
+		if resp := serveRequest(app, req); resp.StatusCode() != 200 || got.Name != tc.name {
+			t.Errorf("%s %v: got %d %q, want %q", tc.uri, tc.headers, resp.StatusCode(), got.Name, tc.name)
+		}
+	}
+
+	req := newRequest(MethodPost, "/b?tag=a&tag=b&id=1&id=2&limit=5&since=2020-01-02T03:04:05Z&flag=x&flag=y", "X-Raw", "raw")
+	req.Header.SetContentType(MIMEApplicationJSON)
+	req.SetBodyString(`{"addr":{"city":"Paris"}}`)
+	if resp := serveRequest(app, req); resp.StatusCode() != 200 {
+		t.Fatalf("got %d %s", resp.StatusCode(), resp.Body())
+	}
+	want := bindReq{
+		Tags:  []string{"a", "b"},
+		IDs:   []int{1, 2},
+		Since: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Raw:   []byte("raw"),
+		Addr:  &bindAddr{City: "Paris"},
+		Flags: []bindFlag{"X", "Y"},
+	}
+	if got.Limit == nil || *got.Limit != 5 {
+		t.Errorf("Limit: got %v", got.Limit)
+	}
+	if got.Opt != nil {
+		t.Errorf("Opt: got %q, want nil", *got.Opt)
+	}
+	got.Limit = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	type req struct {
+		ID    int    `query:"id" validate:"required,min=1"`
+		Email string `query:"email" validate:"email"`
+	}
+	app := New()
+	app.Post("/e", func(c *Ctx) {
+		var r req
+		if err := c.Bind(&r); err != nil {
+			c.Next(err)
+			return
+		}
+		c.Send("ok")
+	})
+	app.Build()
+
+	cases := []struct {
+		uri, body string
+		code      int
+		want      string
+	}{
+		{"/e?id=1", "", 200, "ok"},
+		{"/e?id=x", "", 400, `{"code":400,"message":"id: strconv.ParseInt: parsing \"x\": invalid syntax"}`},
+		{"/e?id=1", `{"id":`, 400, `{"code":400,"message":"unexpected end of JSON input"}`},
+		{"/e?id=-1&email=nope", "", 422, `{"code":422,"errors":[{"field":"id","rule":"min","param":"1","message":"id must be at least 1"},` +
+			`{"field":"email","rule":"email","message":"email must be a valid email"}],"message":"id must be at least 1; email must be a valid email"}`},
+		{"/e", "", 422, `{"code":422,"errors":[{"field":"id","rule":"required","message":"id is required"}],"message":"id is required"}`},
+	}
+	for _, tc := range cases {
+		req := newRequest(MethodPost, tc.uri, HeaderAccept, MIMEApplicationJSON)
+		if tc.body != "" {
+			req.Header.SetContentType(MIMEApplicationJSON)
+			req.SetBodyString(tc.body)
+		}
+		resp := serveRequest(app, req)
+		if resp.StatusCode() != tc.code || string(resp.Body()) != tc.want {
+			t.Errorf("POST %s %s: got %d %s, want %d %s", tc.uri, tc.body, resp.StatusCode(), resp.Body(), tc.code, tc.want)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	type nested struct {
+		Zip string `json:"zip" validate:"required,min=5,max=5"`
+	}
+	type rules struct {
+		Name   string            `json:"name" validate:"required"`
+		Age    int               `json:"age" validate:"min=18,max=130"`
+		Score  float64           `json:"score" validate:"max=1.5"`
+		Nick   string            `json:"nick" validate:"min=2,max=4"` // runes
+		Tags   []string          `json:"tags" validate:"max=2"`
+		Labels map[string]string `json:"labels" validate:"min=1"`
+		Email  string            `json:"email" validate:"email"`
+		Sort   string            `json:"sort" validate:"oneof=asc desc"`
+		Level  *int              `json:"level" validate:"required,oneof=1 2"`
+		Home   nested            `json:"home"`
+		Work   *nested           `json:"work"`
+	}
+	one, three := 1, 3
+	valid := func() rules {
+		return rules{Name: "a", Age: 20, Level: &one, Home: nested{Zip: "12345"}}
+	}
+	cases := []struct {
+		name   string
+		change func(r *rules)
+		fields string
+	}{
+		{"valid", func(r *rules) {}, ""},
+		{"zero optional", func(r *rules) { r.Age, r.Nick, r.Email, r.Sort = 0, "", "", "" }, ""},
+		{"required", func(r *rules) { r.Name, r.Level = "", nil }, "name:required level:required"},
+		{"min max number", func(r *rules) { r.Age, r.Score = 17, 1.6 }, "age:min score:max"},
+		{"max number", func(r *rules) { r.Age = 131 }, "age:max"},
+		{"length", func(r *rules) { r.Nick = "日本語です" }, "nick:max"},
+		{"length min", func(r *rules) { r.Nick = "日" }, "nick:min"},
+		{"runes", func(r *rules) { r.Nick = "日本語" }, ""},
+		{"slice map", func(r *rules) { r.Tags, r.Labels = []string{"a", "b", "c"}, map[string]string{} }, "tags:max labels:min"},
+		{"email", func(r *rules) { r.Email = "a@b" }, "email:email"},
+		{"email ok", func(r *rules) { r.Email = "a@b.io" }, ""},
+		{"oneof", func(r *rules) { r.Sort, r.Level = "up", &three }, "sort:oneof level:oneof"},
+		{"nested", func(r *rules) { r.Home.Zip, r.Work = "1", &nested{} }, "home.zip:min work.zip:required"},
+	}
+	for _, tc := range cases {
+		r := valid()
+		tc.change(&r)
+		err := Validate(&r)
+		var fields []string
+		if err != nil {
+			ve, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			for _, e := range ve.Errors {
+				fields = append(fields, e.Field+":"+e.Rule)
+			}
+		}
+		if got := strings.Join(fields, " "); got != tc.fields {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.fields)
+		}
+	}
+}
+
+type badRule struct {
+	Name string `validate:"requird"`
+}
+
+type badNested struct {
+	Inner struct {
+		N bool `validate:"min=1"`
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want string
+	}{
+		{&badRule{}, `validate badRule.Name: unknown rule "requird"`},
+		{&badNested{}, "validate N: min on bool"},
+		{&struct {
+			N int `validate:"max=ten"`
+		}{}, "max needs a number"},
+		{&struct {
+			S string `validate:"oneof="`
+		}{}, "oneof needs values"},
+	}
+	for _, tc := range cases {
+		err := Validate(tc.v)
+		if _, ok := err.(*ValidationError); ok || err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%T: got %v, want %q", tc.v, err, tc.want)
+		}
+		if err2 := Validate(tc.v); err2 == nil || err2.Error() != err.Error() {
+			t.Errorf("%T: cached %v", tc.v, err2)
+		}
+	}
+
+	// the request struct of a typed handler is checked at registration
+	expectFatal(t, `unknown rule "requird"`, func() {
+		New().Post("/bad", func(c *Ctx, r *badRule) error { return nil })
+	})
+}
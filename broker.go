@@ -0,0 +1,152 @@
+package web
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xs23933/web/cmap"
+)
+
+// brokerBuffer events queued per subscriber, slower subscribers are closed
+// and resume with Last-Event-ID.
+const brokerBuffer = 64
+
+// Broker 按主题分发 server-sent events
+// published events get an increasing ID if they have none and are kept
+// in the replay buffer, reconnecting clients get what they missed.
+//
+//  news := web.NewBroker()
+//  app.Get("/news/:topic", func(c *web.Ctx) {
+//  	news.Serve(c, c.Params("topic"), "all")
+//  })
+//  news.Publish("sports", web.Event{Event: "score", Data: score})
+type Broker struct {
+	topics cmap.ConcurrentMap // topic => map[*subscriber]struct{}, replaced on change
+	config SSEConfig
+	seq    uint64
+}
+
+type subscriber struct {
+	stream *EventStream
+	events chan Event
+}
+
+// NewBroker 创建 Broker, config is used by Serve,
+// Replay defaults to NewReplayBuffer(100).
+func NewBroker(config ...SSEConfig) *Broker {
+	b := &Broker{topics: cmap.New()}
+	if len(config) > 0 {
+		b.config = config[0]
+	}
+	if b.config.Replay == nil {
+		b.config.Replay = NewReplayBuffer(100)
+	}
+	return b
+}
+
+// Publish 发送事件到 topic 的订阅者
+func (b *Broker) Publish(topic string, e Event) {
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(atomic.AddUint64(&b.seq, 1), 10)
+	}
+	b.config.Replay.Add(topic, e)
+	v, ok := b.topics.Get(topic)
+	if !ok {
+		return
+	}
+	for sub := range v.(map[*subscriber]struct{}) {
+		select {
+		case sub.events <- e:
+		default:
+			sub.stream.close() // Close waits for the stalled write
+		}
+	}
+}
+
+// Subscribe 订阅 topics, events missed since Last-Event-ID are sent first.
+// The subscription ends with the stream or by calling unsubscribe.
+func (b *Broker) Subscribe(s *EventStream, topics ...string) (unsubscribe func()) {
+	sub := &subscriber{stream: s, events: make(chan Event, brokerBuffer)}
+	for _, topic := range topics {
+		b.topics.Upsert(topic, sub, func(exist bool, old, v interface{}) interface{} {
+			subs := map[*subscriber]struct{}{sub: {}}
+			if exist {
+				for k := range old.(map[*subscriber]struct{}) {
+					subs[k] = struct{}{}
+				}
+			}
+			return subs
+		})
+	}
+	var once sync.Once
+	stop := make(chan struct{})
+	unsubscribe = func() {
+		once.Do(func() {
+			close(stop)
+			for _, topic := range topics {
+				b.unsubscribe(topic, sub)
+			}
+		})
+	}
+
+	// subscribe first, events published while replaying are skipped
+	replayed := make(map[string]bool)
+	if id := s.LastEventID(); id != "" {
+		for _, e := range b.config.Replay.Since(id, topics...) {
+			replayed[e.ID] = true
+			s.Send(e)
+		}
+	}
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case e := <-sub.events:
+				if !replayed[e.ID] {
+					s.Send(e)
+				}
+			case <-s.Done():
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return unsubscribe
+}
+
+func (b *Broker) unsubscribe(topic string, sub *subscriber) {
+	b.topics.Upsert(topic, sub, func(exist bool, old, v interface{}) interface{} {
+		subs := make(map[*subscriber]struct{})
+		if exist {
+			for k := range old.(map[*subscriber]struct{}) {
+				if k != sub {
+					subs[k] = struct{}{}
+				}
+			}
+		}
+		return subs
+	})
+	b.topics.RemoveCb(topic, func(key string, v interface{}, exists bool) bool {
+		return exists && len(v.(map[*subscriber]struct{})) == 0
+	})
+}
+
+// Subscribers 订阅 topic 的连接数
+func (b *Broker) Subscribers(topic string) int {
+	if v, ok := b.topics.Get(topic); ok {
+		return len(v.(map[*subscriber]struct{}))
+	}
+	return 0
+}
+
+// Serve 以 SSE 响应 c 并订阅 topics 直到客户端断开
+func (b *Broker) Serve(c *Ctx, topics ...string) {
+	cfg := b.config
+	cfg.Replay = nil // Subscribe replays the topics
+	c.SSE(func(s *EventStream) {
+		defer b.Subscribe(s, topics...)()
+		<-s.Done()
+	}, cfg)
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// racingReplay publishes while a subscriber replays
+type racingReplay struct {
+	ReplayBuffer
+	publish func()
+}
+
+func (r *racingReplay) Since(id string, topics ...string) []Event {
+	if r.publish != nil {
+		r.publish()
+	}
+	return r.ReplayBuffer.Since(id, topics...)
+}
+
+// waitFor polls cond for up to 2s
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+func TestBrokerReplay(t *testing.T) {
+	replay := &racingReplay{ReplayBuffer: NewReplayBuffer(10)}
+	b := NewBroker(SSEConfig{Replay: replay})
+	b.Publish("t", Event{Data: "1"})
+	b.Publish("t", Event{Data: "2"})
+	b.Publish("other", Event{Data: "3"})
+	b.Publish("t", Event{Data: "4"})
+	// published after subscribing and before replaying: in both
+	replay.publish = func() { b.Publish("t", Event{Data: "5"}) }
+
+	var buf syncBuffer
+	s := newEventStream(bufio.NewWriter(&buf), "1")
+	unsubscribe := b.Subscribe(s, "t")
+	replay.publish = nil
+	b.Publish("t", Event{Data: "6"})
+
+	want := "id: 2\ndata: 2\n\nid: 4\ndata: 4\n\nid: 5\ndata: 5\n\nid: 6\ndata: 6\n\n"
+	waitFor(t, "event 6", func() bool { return strings.Contains(buf.String(), "id: 6") })
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if n := b.Subscribers("t"); n != 1 {
+		t.Errorf("Subscribers = %d, want 1", n)
+	}
+	unsubscribe()
+	unsubscribe()
+	if n := b.Subscribers("t"); n != 0 {
+		t.Errorf("Subscribers after unsubscribe = %d, want 0", n)
+	}
+
+	// unknown Last-Event-ID: live events only
+	buf = syncBuffer{}
+	s = newEventStream(bufio.NewWriter(&buf), "gone")
+	defer b.Subscribe(s, "t")()
+	b.Publish("t", Event{ID: "x", Data: "7"})
+	waitFor(t, "event 7", func() bool { return buf.String() != "" })
+	if got := buf.String(); got != "id: x\ndata: 7\n\n" {
+		t.Errorf("unknown Last-Event-ID: got %q", got)
+	}
+}
+
+// blockingWriter blocks writes until release is closed
+type blockingWriter struct{ release chan struct{} }
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	slow := blockingWriter{make(chan struct{})}
+	s := newEventStream(bufio.NewWriter(slow), "")
+	b.Subscribe(s, "t")
+	var fast syncBuffer
+	f := newEventStream(bufio.NewWriter(&fast), "")
+	defer b.Subscribe(f, "t")()
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < brokerBuffer+2; i++ {
+			b.Publish("t", Event{Data: i})
+			// keep the fast subscriber from falling behind
+			for want := fmt.Sprintf("data: %d\n", i); !strings.Contains(fast.String(), want); {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("slow subscriber not closed")
+	}
+	select {
+	case <-f.Done():
+		t.Fatal("fast subscriber closed")
+	default:
+	}
+	close(slow.release) // the stalled write returns
+	waitFor(t, "slow subscriber removed", func() bool { return b.Subscribers("t") == 1 })
+}
+
+func TestBrokerServeDisconnect(t *testing.T) {
+	b := NewBroker(SSEConfig{Heartbeat: 20 * time.Millisecond})
+	app := New()
+	app.Get("/events/:topic", func(c *Ctx) {
+		b.Serve(c, c.Params("topic"))
+	})
+	app.Build()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Server.Serve(ln)
+	defer app.Server.Shutdown()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/events/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(resp.Body)
+	if line, _ := r.ReadString('\n'); line != ":\n" {
+		t.Fatalf("first line %q", line)
+	}
+	waitFor(t, "subscriber", func() bool { return b.Subscribers("t") == 1 })
+	b.Publish("t", Event{Event: "news", Data: "hi"})
+	var got []string
+	for len(got) < 3 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != "\n" && line != ":\n" { // heartbeats
+			got = append(got, line)
+		}
+	}
+	if want := "id: 1\nevent: news\ndata: hi\n"; strings.Join(got, "") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, ""), want)
+	}
+
+	resp.Body.Close()
+	waitFor(t, "unsubscribe on disconnect", func() bool { return b.Subscribers("t") == 0 })
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"strings"
+)
+
+// Codec 编解码器
+// ReadBody and Bind decode request bodies by Content-Type,
+// Encode picks the response codec by Accept.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type stdJSON struct{}
+
+func (stdJSON) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (stdJSON) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type stdXML struct{}
+
+func (stdXML) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (stdXML) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+// Built-in codecs, RegisterCodec replaces them.
+var (
+	JSONCodec Codec = stdJSON{}
+	XMLCodec  Codec = stdXML{}
+)
+
+var defaultCodecs = []struct {
+	mime  string
+	codec Codec
+}{
+	{MIMEApplicationJSON, JSONCodec},
+	{MIMEApplicationXML, XMLCodec},
+	{MIMETextXML, XMLCodec},
+}
+
+// RegisterCodec 注册 Content-Type 的编解码器
+// registering application/json swaps the JSON implementation of
+// Ctx.JSON, ToJSON, JSONP and the error responses, a nil codec removes mime.
+// Mounted and host apps use the codecs of their parents too.
+//
+//  app.RegisterCodec(web.MIMEApplicationMsgPack, codec.MsgPack)
+//  app.RegisterCodec(web.MIMEApplicationJSON, jsoniterCodec{})
+func (c *Core) RegisterCodec(mime string, codec Codec) *Core {
+	mime = mediaType(mime)
+	if mime == "" {
+		log.Fatalf("RegisterCodec: empty media type")
+	}
+	if c.codecs == nil {
+		c.codecs = make(map[string]Codec)
+	}
+	if _, ok := c.codecs[mime]; !ok {
+		c.codecOrder = append(c.codecOrder, mime)
+	}
+	c.codecs[mime] = codec
+	return c
+}
+
+// codec returns the codec of mime, nil if there is none.
+func (c *Core) codec(mime string) Codec {
+	for app := c; app != nil; app = app.parent {
+		if codec, ok := app.codecs[mime]; ok {
+			return codec
+		}
+	}
+	for _, d := range defaultCodecs {
+		if d.mime == mime {
+			return d.codec
+		}
+	}
+	return nil
+}
+
+// codecMIMEs media types with a codec, built-in first then registration order.
+func (c *Core) codecMIMEs() []string {
+	var apps []*Core
+	for app := c; app != nil; app = app.parent {
+		apps = append([]*Core{app}, apps...)
+	}
+	mimes := make([]string, 0, len(defaultCodecs))
+	seen := make(map[string]bool)
+	add := func(mime string) {
+		if !seen[mime] && c.codec(mime) != nil {
+			mimes = append(mimes, mime)
+		}
+		seen[mime] = true
+	}
+	for _, d := range defaultCodecs {
+		add(d.mime)
+	}
+	for _, app := range apps {
+		for _, mime := range app.codecOrder {
+			add(mime)
+		}
+	}
+	return mimes
+}
+
+// jsonCodec the registered JSON codec.
+func (c *Core) jsonCodec() Codec {
+	if codec := c.codec(MIMEApplicationJSON); codec != nil {
+		return codec
+	}
+	return JSONCodec
+}
+
+// bodyCodec the codec decoding a request body of ctype, text/plain is json.
+func (c *Core) bodyCodec(ctype string) Codec {
+	mime := mediaType(ctype)
+	if mime == MIMETextPlain {
+		return c.jsonCodec()
+	}
+	if mime == "" {
+		return nil
+	}
+	return c.codec(mime)
+}
+
+// mediaType "Application/JSON; charset=utf-8" -> "application/json"
+func mediaType(ctype string) string {
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(ctype))
+}
+
+// Encode 按 Accept 选择编解码器发送 v
+// the codecs of RegisterCodec are offered, json first,
+// returns a 406 *Error when the client accepts none.
+//
+//  return c.Encode(201, user)
+func (c *Ctx) Encode(status int, v interface{}) error {
+	c.Response.Header.Add(HeaderVary, HeaderAccept)
+	mime := c.Accepts(c.codecMIMEs()...)
+	if mime == "" {
+		return NewError(406)
+	}
+	raw, err := c.codec(mime).Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.Response.SetStatusCode(status)
+	c.Response.Header.SetContentType(mime)
+	c.Response.SetBodyRaw(raw)
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package codec MessagePack, CBOR and YAML codecs for web.Core.RegisterCodec
+//
+//  app.RegisterCodec(web.MIMEApplicationMsgPack, codec.MsgPack)
+//  app.RegisterCodec(web.MIMEApplicationCBOR, codec.CBOR)
+//  app.RegisterCodec(web.MIMEApplicationYAML, codec.YAML)
+//
+// Struct fields use the msgpack, cbor or yaml tag, json tags are the fallback
+// of msgpack and cbor.
+package codec
+
+import (
+	"bytes"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v2"
+)
+
+// Codecs, they implement web.Codec.
+var (
+	MsgPack = msgpackCodec{}
+	CBOR    = cborCodec{}
+	YAML    = yamlCodec{}
+)
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
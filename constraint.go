@@ -0,0 +1,87 @@
+package web
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// constraint 参数约束, 不满足时继续匹配下一个路由
+//
+//  /user/:id<int>
+//  /post/:slug<regex(^[a-z-]+$)>
+//  /log/:date<datetime(2006-01-02)>
+//  /file/:id<uuid>?
+type constraint struct {
+	name string
+	arg  string
+	re   *regexp.Regexp
+}
+
+var uuidRegexp = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// parseConstraint parse "int", "regex(...)" or "datetime(...)",
+// unknown constraints and a "/" (a param matches one segment) stop the registration.
+func parseConstraint(s string) *constraint {
+	if s == "" {
+		return nil
+	}
+	if strings.Contains(s, "/") {
+		log.Fatalf("Router: constraint %s, a param matches one path segment without /", s)
+	}
+	c := &constraint{name: s}
+	if i := strings.IndexByte(s, '('); i > 0 && strings.HasSuffix(s, ")") {
+		c.name, c.arg = s[:i], s[i+1:len(s)-1]
+	}
+	switch c.name {
+	case "int", "bool", "uuid":
+	case "regex":
+		re, err := regexp.Compile("^(?:" + c.arg + ")$")
+		if err != nil {
+			log.Fatalf("Router: invalid constraint %s: %v", s, err)
+		}
+		c.re = re
+	case "datetime":
+		if c.arg == "" {
+			log.Fatalf("Router: constraint %s needs a layout", s)
+		}
+	default:
+		log.Fatalf("Router: unknown constraint %s", s)
+	}
+	return c
+}
+
+// match reports whether the param value satisfies the constraint, nil matches all.
+func (c *constraint) match(v string) bool {
+	if c == nil {
+		return true
+	}
+	switch c.name {
+	case "int":
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err == nil
+	case "bool":
+		_, err := strconv.ParseBool(v)
+		return err == nil
+	case "uuid":
+		return uuidRegexp.MatchString(v)
+	case "regex":
+		return c.re.MatchString(v)
+	case "datetime":
+		_, err := time.Parse(c.arg, v)
+		return err == nil
+	}
+	return false
+}
+
+func (c *constraint) String() string {
+	if c == nil {
+		return ""
+	}
+	if c.arg == "" {
+		return c.name
+	}
+	return c.name + "(" + c.arg + ")"
+}
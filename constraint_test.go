@@ -0,0 +1,110 @@
+package web
+
+import "testing"
+
+func TestConstraints(t *testing.T) {
+	app := New()
+	send := func(name string) func(*Ctx) {
+		return func(c *Ctx) { c.Send(name + "=" + c.Params("v")) }
+	}
+	app.Get("/int/:v<int>", send("int"))
+	app.Get("/bool/:v<bool>", send("bool"))
+	app.Get("/uuid/:v<uuid>", send("uuid"))
+	app.Get("/post/:v<regex(^[A-Z]+$)>", send("upper"))
+	app.Get("/post/:v<regex([a-z0-9-]+)>", send("slug"))
+	app.Get("/log/:v<datetime(2006-01-02)>", send("date"))
+	app.Get("/file/:v<uuid>?", send("file"))
+	app.Get("/:kind/:v", send("any"))
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		body string
+	}{
+		{"/int/42", "int=42"},
+		{"/int/-7", "int=-7"},
+		{"/int/4x", "any=4x"},
+		{"/bool/true", "bool=true"},
+		{"/bool/yes", "any=yes"},
+		{"/uuid/0f8fad5b-d9cb-469f-a165-70867728950e", "uuid=0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{"/uuid/0f8fad5b", "any=0f8fad5b"},
+		{"/post/ABC", "upper=abc"}, // case-insensitive routing lowercases params
+		{"/post/a-1", "slug=a-1"},
+		{"/post/a_1", "any=a_1"},
+		{"/log/2020-02-29", "date=2020-02-29"},
+		{"/log/2021-02-29", "any=2021-02-29"},
+		{"/file", "file="},
+		{"/file/nope", "any=nope"},
+	}
+	for _, tc := range cases {
+		if body := string(serve(app, MethodGet, tc.uri).Body()); body != tc.body {
+			t.Errorf("GET %s: got %q, want %q", tc.uri, body, tc.body)
+		}
+	}
+
+	strict := New(&Options{CaseSensitive: true})
+	strict.Get("/post/:v<regex(^[A-Z]+$)>", send("upper"))
+	strict.Build()
+	if resp := serve(strict, MethodGet, "/post/ABC"); string(resp.Body()) != "upper=ABC" {
+		t.Errorf("CaseSensitive GET /post/ABC: got %d %q", resp.StatusCode(), resp.Body())
+	}
+	if code := serve(strict, MethodGet, "/post/abc").StatusCode(); code != 404 {
+		t.Errorf("CaseSensitive GET /post/abc: got %d, want 404", code)
+	}
+}
+
+func TestConstraintFatal(t *testing.T) {
+	cases := map[string]string{
+		"unknown": "/u/:id<integer>",
+		"regex":   "/u/:id<regex([a-z)>",
+		"layout":  "/u/:id<datetime>",
+		"slash":   "/u/:day<regex(\\d+/\\d+)>",
+	}
+	msgs := map[string]string{
+		"unknown": "unknown constraint integer",
+		"regex":   "missing closing ]",
+		"layout":  "constraint datetime needs a layout",
+		"slash":   "matches one path segment",
+	}
+	for name, path := range cases {
+		path := path
+		t.Run(name, func(t *testing.T) {
+			expectFatal(t, msgs[name], func() {
+				New().Get(path, func(c *Ctx) {})
+			})
+		})
+	}
+}
+
+func TestParamsTyped(t *testing.T) {
+	app := New(&Options{CaseSensitive: true})
+	var got []interface{}
+	app.Get("/p/:n/:id", func(c *Ctx) {
+		got = []interface{}{
+			c.ParamsInt("n"), c.ParamsInt("n", 7), c.ParamsInt64("n", 8), c.ParamsBool("n", true),
+			c.ParamsUUID("id"), c.ParamsUUID("id", "none"),
+		}
+	})
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		want []interface{}
+	}{
+		{"/p/12/0F8FAD5B-D9CB-469F-A165-70867728950E", []interface{}{12, 12, int64(12), true, "0f8fad5b-d9cb-469f-a165-70867728950e", "0f8fad5b-d9cb-469f-a165-70867728950e"}},
+		{"/p/x/y", []interface{}{0, 7, int64(8), true, "", "none"}},
+		{"/p/0/y", []interface{}{0, 0, int64(0), false, "", "none"}},
+	}
+	for _, tc := range cases {
+		got = nil
+		serve(app, MethodGet, tc.uri)
+		if len(got) != len(tc.want) {
+			t.Fatalf("GET %s: not served", tc.uri)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("GET %s: value %d got %#v, want %#v", tc.uri, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package web
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"log"
 	"mime/multipart"
@@ -10,6 +8,7 @@ import (
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,11 +22,27 @@ type Ctx struct {
 	*Core
 	*fasthttp.RequestCtx
 	*Route
+	app    *Core // app serving the request, Core is the app of the current route
 	index  int
 	method string
 	path   string
 	values []string
 	err    error
+
+	host       *Core    // app of the matched host, served after the Use middleware of app
+	hostKeys   []string // {name} labels of the matched host
+	hostValues []string
+	meta       map[string]interface{} // see SetMeta
+	ran        []uint64               // Route.once of the handlers run
+
+	// router lookup state, reused by the pool
+	matches     []routeMatch
+	pvalues     []string
+	pstack      []string
+	cursor      int
+	matched     bool
+	matchMethod string
+	matchPath   string
 }
 
 // Cookie struct
@@ -66,6 +81,18 @@ func releaseCtx(c *Ctx) {
 	c.values = nil
 	c.RequestCtx = nil
 	c.err = nil
+	c.host = nil
+	c.hostKeys = nil
+	c.hostValues = c.hostValues[:0]
+	c.meta = nil
+	c.ran = c.ran[:0]
+	c.matches = c.matches[:0]
+	c.pvalues = c.pvalues[:0]
+	c.pstack = c.pstack[:0]
+	c.cursor = 0
+	c.matched = false
+	c.matchMethod = ""
+	c.matchPath = ""
 	poolCtx.Put(c)
 }
 
@@ -181,7 +208,7 @@ func (c *Ctx) SaveFile(fileheader *multipart.FileHeader, path string) error {
 // SendStatus 发送 http code
 func (c *Ctx) SendStatus(code int) {
 	c.Response.SetStatusCode(code)
-	if len(c.Response.Body()) == 0 {
+	if !c.hasBody() {
 		c.Response.SetBodyString(statusMessages[code])
 	}
 }
@@ -218,27 +245,85 @@ func (c *Ctx) Write(bodies ...interface{}) {
 }
 
 // Params is used to get the route parameters.
+// {name} labels of the matched Host are params too.
 func (c *Ctx) Params(k string) (v string) {
-	if c.Route.Params == nil {
-		return
+	if c.Route != nil {
+		for i := 0; i < len(c.Route.Params) && i < len(c.values); i++ {
+			if (c.Route.Params)[i] == k {
+				return c.values[i]
+			}
+		}
+	}
+	return c.hostParam(k)
+}
+
+// ParamsInt 获取 int 参数, 无法解析时返回默认值
+func (c *Ctx) ParamsInt(k string, def ...int) int {
+	v, err := strconv.Atoi(c.Params(k))
+	if err != nil && len(def) > 0 {
+		return def[0]
 	}
-	for i := 0; i < len(c.Route.Params); i++ {
-		if (c.Route.Params)[i] == k {
-			return c.values[i]
+	return v
+}
+
+// ParamsInt64 获取 int64 参数, 无法解析时返回默认值
+func (c *Ctx) ParamsInt64(k string, def ...int64) int64 {
+	v, err := strconv.ParseInt(c.Params(k), 10, 64)
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return v
+}
+
+// ParamsBool 获取 bool 参数, 无法解析时返回默认值
+func (c *Ctx) ParamsBool(k string, def ...bool) bool {
+	v, err := strconv.ParseBool(c.Params(k))
+	if err != nil && len(def) > 0 {
+		return def[0]
+	}
+	return v
+}
+
+// ParamsUUID 获取 uuid 参数 (小写), 格式错误时返回默认值
+func (c *Ctx) ParamsUUID(k string, def ...string) string {
+	v := c.Params(k)
+	if !uuidRegexp.MatchString(v) {
+		if len(def) > 0 {
+			return def[0]
 		}
+		return ""
 	}
-	return
+	return strings.ToLower(v)
 }
 
 // Next 执行下一个操作
+// with an error the chain stops and the error handler sends the response.
 func (c *Ctx) Next(err ...error) {
 	c.Route = nil
 	c.values = nil
-	if len(err) > 0 {
+	if len(err) > 0 && err[0] != nil {
 		c.err = err[0]
+		c.handleError(c, c.err)
 		return
 	}
-	c.nextRoute(c)
+	c.app.nextRoute(c)
+}
+
+// errorFormat pick json, html or plain text for error responses.
+func (c *Ctx) errorFormat() string {
+	if c.Get(HeaderXRequestedWith) == "XMLHttpRequest" {
+		return MIMEApplicationJSON
+	}
+	accept := c.Get(HeaderAccept)
+	j := strings.Index(accept, "json")
+	h := strings.Index(accept, MIMETextHTML)
+	switch {
+	case j >= 0 && (h < 0 || j < h):
+		return MIMEApplicationJSON
+	case h >= 0:
+		return MIMETextHTML
+	}
+	return MIMETextPlain
 }
 
 // Router returns the matched Route struct.
@@ -341,7 +426,7 @@ func (c *Ctx) IPs() []string {
 
 // JSON 发送 json 数据
 func (c *Ctx) JSON(data interface{}) error {
-	raw, err := json.Marshal(&data)
+	raw, err := c.jsonCodec().Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -351,25 +436,27 @@ func (c *Ctx) JSON(data interface{}) error {
 }
 
 // ToJSON 返回js数据处理错误
+// data and err are wrapped by Options.Envelope.
 func (c *Ctx) ToJSON(data interface{}, err error) error {
+	status, ctype, body := c.envelope().Wrap(c, data, err)
+	raw, err := c.jsonCodec().Marshal(body)
 	if err != nil {
-		return c.JSON(map[string]interface{}{
-			"status": false,
-			"result": data,
-			"msg":    err.Error(),
-		})
+		return err
 	}
-
-	return c.JSON(map[string]interface{}{
-		"status": true,
-		"result": data,
-		"msg":    "success",
-	})
+	if status > 0 {
+		c.Response.SetStatusCode(status)
+	}
+	if ctype == "" {
+		ctype = MIMEApplicationJSON
+	}
+	c.Response.Header.SetContentType(ctype)
+	c.Response.SetBodyRaw(raw)
+	return nil
 }
 
 // JSONP 发送jsonp 数据
 func (c *Ctx) JSONP(data interface{}, callback ...string) error {
-	raw, err := json.Marshal(&data)
+	raw, err := c.jsonCodec().Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -471,15 +558,13 @@ func (c *Ctx) Hostname() string {
 }
 
 // ReadBody 读取body 数据
+// bodies are decoded by the codec of the Content-Type, text/plain as json.
 func (c *Ctx) ReadBody(out interface{}) error {
 	ctype := getString(c.Request.Header.ContentType())
+	if codec := c.bodyCodec(ctype); codec != nil {
+		return codec.Unmarshal(c.Request.Body(), out)
+	}
 	switch {
-	// application/json text/plain
-	case strings.HasPrefix(ctype, MIMEApplicationJSON), strings.HasPrefix(ctype, MIMETextPlain):
-		return json.Unmarshal(c.Request.Body(), out)
-	// application/xml text/xml
-	case strings.HasPrefix(ctype, MIMEApplicationXML), strings.HasPrefix(ctype, MIMETextXML):
-		return xml.Unmarshal(c.Request.Body(), out)
 	// application/x-www-form-urlencoded
 	case strings.HasPrefix(ctype, MIMEApplicationForm):
 		data, err := url.ParseQuery(getString(c.PostBody()))
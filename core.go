@@ -1,21 +1,27 @@
 package web
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"html"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"os"
-	"os/exec"
 	"reflect"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 )
 
 // idea from nodejs koa
@@ -23,6 +29,8 @@ import (
 // Options all options
 type Options struct {
 	Prefork bool // multiple go processes listening on the some port
+	// PreforkChildren 子进程数量 default: NumCPU()/2
+	PreforkChildren int
 	// ETag 发送etag
 	ETag       bool
 	ServerName string
@@ -36,6 +44,26 @@ type Options struct {
 	MaxRequestBodySize int
 	Debug              bool
 	ViewEngine         ViewEngine
+	// ErrorHandler 处理 Ctx.Next(err) 及 handler 返回的错误
+	// default: DefaultErrorHandler
+	ErrorHandler func(*Ctx, error)
+	// HandleSignals 收到 SIGINT/SIGTERM 时优雅关闭
+	HandleSignals bool
+	// ShutdownTimeout 优雅关闭最长等待时间 default: 10s
+	ShutdownTimeout time.Duration
+	// PrintRoutes 启动时打印路由表 PrintColored, PrintPlain, PrintJSON or PrintNone
+	PrintRoutes RoutePrinter
+	// CaseSensitive 区分大小写 /Files 与 /files 是不同路由, Params 保留大小写
+	// otherwise paths and params are lowercased, regex constraints ignore case.
+	CaseSensitive bool
+	// StrictRouting 区分结尾斜杠 /foo 与 /foo/ 是不同路由
+	StrictRouting bool
+	// RedirectSlash 301 到带/不带结尾斜杠的路由, 不再静默匹配
+	// the registered form is canonical: with /b/ registered /b redirects to /b/.
+	// other methods than GET and HEAD get 308 to keep the body.
+	RedirectSlash bool
+	// Envelope ToJSON 的包装格式 LegacyEnvelope (default), BareEnvelope or ProblemEnvelope
+	Envelope Envelope
 }
 
 // Core core class
@@ -43,6 +71,24 @@ type Core struct {
 	*Options
 	*fasthttp.Server
 	routes []*Route
+	tree   tree
+	hooks  hooks
+	names  map[string]*Route // named routes for URL
+	last   []*Route          // routes of the last registration, for Name
+	chains int               // registrations count, routes of one share it
+
+	parent      *Core   // app c is mounted into
+	mountPrefix string  // prefix in parent
+	mounts      []*Core // mounted sub apps
+	hosts       []*hostRoute
+
+	codecs     map[string]Codec // by media type, see RegisterCodec
+	codecOrder []string
+
+	mu       sync.Mutex
+	closing  chan struct{} // closed when Shutdown finished
+	hijacked map[uint64]func()
+	hijackID uint64
 }
 
 // Static struct
@@ -56,6 +102,8 @@ type Static struct {
 // New new core
 func New(opts ...*Options) *Core {
 	c := new(Core)
+	c.tree = make(tree)
+	c.names = make(map[string]*Route)
 
 	c.Options = new(Options)
 	if len(opts) == 1 {
@@ -80,6 +128,9 @@ func (c *Core) RegView(viewEngine ViewEngine) {
 // Use context.View to render templates to the client instead.
 // Returns an error on failure, otherwise nil.
 func (c *Core) View(writer io.Writer, filename string, layout string, bind interface{}) error {
+	if c.ViewEngine == nil && c.parent != nil {
+		return c.parent.View(writer, filename, layout, bind)
+	}
 	return c.ViewEngine.ExecuteWriter(writer, filename, layout, bind)
 }
 
@@ -88,7 +139,7 @@ func (c *Core) LoadTpls(tpls map[string]string) error {
 	return c.ViewEngine.LoadTpls(tpls)
 }
 
-func (c *Core) regStatic(prefix, root string, config ...Static) {
+func (c *Core) regStatic(prefix, root string, middleware []func(*Ctx), config ...Static) {
 	if prefix == "" {
 		prefix = "/"
 	}
@@ -102,7 +153,9 @@ func (c *Core) regStatic(prefix, root string, config ...Static) {
 		wildcard = true
 		prefix = "/"
 	}
-	prefix = strings.ToLower(prefix)
+	if !c.CaseSensitive {
+		prefix = strings.ToLower(prefix)
+	}
 	// For security we want to restrict to the current work directory.
 	if len(root) == 0 {
 		root = "."
@@ -130,7 +183,14 @@ func (c *Core) regStatic(prefix, root string, config ...Static) {
 		CompressedFileSuffix: ".tar.gz",
 		CacheDuration:        10 * time.Second,
 		IndexNames:           []string{"index.html"},
-		PathRewrite:          fasthttp.NewPathPrefixStripper(stripper),
+		PathRewrite: func(fctx *fasthttp.RequestCtx) []byte {
+			// a mounted app sees the full path
+			path := fctx.Path()
+			if n := len(c.mountPath()) + stripper; len(path) >= n {
+				path = path[n:]
+			}
+			return path
+		},
 		PathNotFound: func(ctx *fasthttp.RequestCtx) {
 			ctx.Response.SetStatusCode(404)
 			ctx.Response.SetBodyString("Not Found")
@@ -146,7 +206,20 @@ func (c *Core) regStatic(prefix, root string, config ...Static) {
 		}
 	}
 	fileHandler := fs.NewRequestHandler()
-	c.routes = append(c.routes, &Route{
+	c.last = nil
+	c.chains++
+	// group middleware matches the same prefix as the files
+	for i := range middleware {
+		c.addRoute(&Route{
+			isMiddleware: true,
+			isSlash:      isSlash,
+			Method:       "*",
+			Path:         prefix,
+			Handler:      middleware[i],
+			handler:      funcName(middleware[i]),
+		})
+	}
+	c.addRoute(&Route{
 		isMiddleware: true,
 		isSlash:      isSlash,
 		Method:       "*",
@@ -156,7 +229,7 @@ func (c *Core) regStatic(prefix, root string, config ...Static) {
 			if ctx.method == "GET" || ctx.method == "HEAD" {
 				// Do stuff
 				if wildcard {
-					ctx.Request.SetRequestURI(prefix)
+					ctx.Request.SetRequestURI(c.mountPath() + prefix)
 				}
 				// Serve file
 				fileHandler(ctx.RequestCtx)
@@ -171,133 +244,221 @@ func (c *Core) regStatic(prefix, root string, config ...Static) {
 			}
 			ctx.Next()
 		},
+		handler: "Static(" + root + ")",
 	})
 }
 
 // Static registers a new route with path prefix to serve static files from the provided root directory.
 func (c *Core) Static(prefix, root string, config ...Static) *Core {
-	c.regStatic(prefix, root, config...)
+	c.regStatic(prefix, root, nil, config...)
 	return c
 }
 
 // Use registers a middleware route.
 func (c *Core) Use(args ...interface{}) *Core {
-	path := ""
-	var handlers []func(*Ctx)
-	skip := false // 不需要综合注册
-	for i := 0; i < len(args); i++ {
-		switch arg := args[i].(type) {
-		case string:
-			path = arg
-		case func(*Ctx):
-			handlers = append(handlers, arg)
-		case handle:
-			skip = true
-			c.buildHands(arg)
-		default:
-			log.Fatalf("Use not support %v\n", arg)
+	path, handlers, hands := parseArgs("Use", args)
+	if len(hands) > 0 { // 不需要综合注册
+		for _, hand := range hands {
+			c.buildHands(hand, "", nil, nil)
 		}
-	}
-	if skip {
 		return c
 	}
 
 	c.pushMethod("USE", path, handlers...)
+	c.nameHandlers(args)
 	return c
 }
 
-// Get registers a middleware route.
-func (c *Core) Get(args ...interface{}) *Core {
-	path := ""
-	var handlers []func(*Ctx)
-	skip := false // 不需要综合注册
+// parseArgs split register args to path, handlers and controllers.
+func parseArgs(method string, args []interface{}) (path string, handlers []func(*Ctx), hands []handle) {
 	for i := 0; i < len(args); i++ {
 		switch arg := args[i].(type) {
 		case string:
 			path = arg
-		case func(*Ctx):
-			handlers = append(handlers, arg)
+		case func(*Ctx), func(*Ctx) error:
+			fn, _ := toHandler(arg)
+			handlers = append(handlers, fn)
 		case handle:
-			skip = true
-			c.buildHands(arg)
+			hands = append(hands, arg)
 		default:
-			log.Fatalf("Use not support %v\n", arg)
+			fn, ok := typedHandler(arg)
+			if !ok {
+				log.Fatalf("%s not support %v\n", method, arg)
+			}
+			handlers = append(handlers, fn)
 		}
 	}
-	if skip {
+	return
+}
+
+// toHandler convert supported handler signatures to func(*Ctx),
+// errors returned by func(*Ctx) error go to the error handler,
+// see typedHandler for handlers with a request struct or a result.
+func toHandler(h interface{}) (func(*Ctx), bool) {
+	switch fn := h.(type) {
+	case func(*Ctx):
+		return fn, true
+	case func(*Ctx) error:
+		return func(c *Ctx) {
+			if err := fn(c); err != nil {
+				c.Next(err)
+			}
+		}, true
+	}
+	return typedHandler(h)
+}
+
+// Get registers a route for GET methods.
+func (c *Core) Get(args ...interface{}) *Core {
+	return c.Add(MethodGet, args...)
+}
+
+// Post registers a route for POST methods.
+func (c *Core) Post(args ...interface{}) *Core {
+	return c.Add(MethodPost, args...)
+}
+
+// Put registers a route for PUT methods.
+func (c *Core) Put(args ...interface{}) *Core {
+	return c.Add(MethodPut, args...)
+}
+
+// Delete registers a route for DELETE methods.
+func (c *Core) Delete(args ...interface{}) *Core {
+	return c.Add(MethodDelete, args...)
+}
+
+// Patch registers a route for PATCH methods.
+func (c *Core) Patch(args ...interface{}) *Core {
+	return c.Add(MethodPatch, args...)
+}
+
+// Head registers a route for HEAD methods.
+func (c *Core) Head(args ...interface{}) *Core {
+	return c.Add(MethodHead, args...)
+}
+
+// Opts registers a route for OPTIONS methods.
+// named Opts because Core.Options holds the settings.
+func (c *Core) Opts(args ...interface{}) *Core {
+	return c.Add(MethodOptions, args...)
+}
+
+// Connect registers a route for CONNECT methods.
+func (c *Core) Connect(args ...interface{}) *Core {
+	return c.Add(MethodConnect, args...)
+}
+
+// Trace registers a route for TRACE methods.
+func (c *Core) Trace(args ...interface{}) *Core {
+	return c.Add(MethodTrace, args...)
+}
+
+// All registers a route for all methods.
+func (c *Core) All(args ...interface{}) *Core {
+	return c.Add("ALL", args...)
+}
+
+// Add registers a route for the given method,
+// args is the same as Get: path string, func(*Ctx) handlers or a handle.
+func (c *Core) Add(method string, args ...interface{}) *Core {
+	method = strings.ToUpper(method)
+	path, handlers, hands := parseArgs(method, args)
+	if len(hands) > 0 { // 不需要综合注册
+		for _, hand := range hands {
+			c.buildHands(hand, "", nil, nil)
+		}
 		return c
 	}
 
-	c.pushMethod("GET", path, handlers...)
-	fmt.Printf("| %s\t%s\n", Magenta("GET"), path)
+	c.pushMethod(method, path, handlers...)
+	c.nameHandlers(args)
 
 	return c
 }
 
-func (c *Core) buildHands(hand handle) {
+// buildHands register controller methods,
+// group is the prefix, middleware and Route.once ids of the group it belongs to.
+// Methods listed by Routes() use the explicit verb and path,
+// the others are named by convention: GetUserParam -> GET /user/:param.
+// Every route runs group middleware, Preload, Middleware()[method] then the method,
+// Preload runs once when a method falls through to another route of the controller.
+func (c *Core) buildHands(hand handle, group string, middleware []func(*Ctx), once []uint64) {
 	hand.Init()
 
 	// register routers
 	refCtl := reflect.TypeOf(hand)
 	methodCount := refCtl.NumMethod()
 	valFn := reflect.ValueOf(hand)
-	fmt.Println("+ ---- Auto register router ---- +")
-	prefix := hand.Prefix()
-	c.pushMethod("USE", prefix, hand.Preload)
-
+	ctlName := reflect.Indirect(valFn).Type().Name()
+	prefix := joinPath(group, hand.Prefix())
+	preload := len(middleware)
+	middleware = chain(middleware, hand.Preload)
+	once = onceIDs(once, 1)
+
+	table := handRoutes(hand, ctlName)
+	var methodMw map[string][]func(*Ctx)
+	if h, ok := hand.(methodMiddleware); ok {
+		methodMw = make(map[string][]func(*Ctx))
+		for name, mw := range h.Middleware() {
+			methodMw[name] = mw
+		}
+	}
+	seen := make(map[string]string) // "METHOD path" -> controller method
 	for i := 0; i < methodCount; i++ {
 		m := refCtl.Method(i)
-		name := toNamer(m.Name)
-		switch {
-		case strings.HasPrefix(name, "get"): // GET
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "get")
-				c.pushMethod("GET", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("GET"), name)
-			}
-		case strings.HasPrefix(name, "post"): // POST
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "post")
-				c.pushMethod("POST", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("POST"), name)
-			}
-		case strings.HasPrefix(name, "put"): // PUT
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "put")
-				c.pushMethod("PUT", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("PUT"), name)
-			}
-		case strings.HasPrefix(name, "delete"): // Delete
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "delete")
-				c.pushMethod("DELETE", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("DELETE"), name)
-			}
-		case strings.HasPrefix(name, "patch"): // Delete
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "patch")
-				c.pushMethod("PATCH", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("PATCH"), name)
+		desc, explicit := table[m.Name]
+		if explicit {
+			delete(table, m.Name)
+			desc.Path = joinRoute(prefix, desc.Path)
+		} else {
+			name := toNamer(m.Name)
+			for _, verb := range []string{"get", "post", "put", "delete", "patch", "head", "all"} {
+				if strings.HasPrefix(name, verb) {
+					desc = RouteDescriptor{Method: strings.ToUpper(verb), Path: fixURI(prefix, name, verb)}
+					break
+				}
 			}
-		case strings.HasPrefix(name, "head"): // Delete
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "head")
-				c.pushMethod("HEAD", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("HEAD"), name)
+			if desc.Method == "" {
+				continue
 			}
-		case strings.HasPrefix(name, "all"): // All
-			if fn, ok := (valFn.Method(i).Interface()).(func(*Ctx)); ok {
-				name = fixURI(prefix, name, "all")
-				c.pushMethod("ALL", name, fn)
-				fmt.Printf("| %s\t%s\n", Magenta("ALL"), name)
+		}
+		fn, ok := toHandler(valFn.Method(i).Interface())
+		if !ok {
+			if explicit {
+				log.Fatalf("Router: %s.%s is not a handler", ctlName, m.Name)
 			}
+			continue
+		}
+		c.checkConflict(seen, desc, ctlName+"."+m.Name)
+		mw := chain(middleware, methodMw[m.Name]...)
+		delete(methodMw, m.Name)
+		c.pushMethod(desc.Method, desc.Path, chain(chain(mw, desc.Middleware...), fn)...)
+		markOnce(c.last, once)
+		if desc.Name != "" {
+			c.Name(desc.Name)
+		} else {
+			c.autoName(ctlName + "." + m.Name)
+		}
+		for _, r := range c.last {
+			r.controller = ctlName
 		}
+		c.last[preload].handler = ctlName + ".Preload"
+		c.last[len(c.last)-1].handler = ctlName + "." + m.Name
+	}
+	for name := range table {
+		log.Fatalf("Router: %s.%s listed by Routes() not found", ctlName, name)
+	}
+	for name := range methodMw {
+		log.Fatalf("Router: %s.%s listed by Middleware() is not a route", ctlName, name)
 	}
-	fmt.Println("+ ------------------------------ +")
 
-	c.pushMethod("GET", "/check", func(ctx *Ctx) {
-		ctx.Send("ok")
-	})
+	if !c.tree.has(MethodGet, "/check", c.strictSlash()) { // once for all controllers
+		c.pushMethod("GET", "/check", func(ctx *Ctx) {
+			ctx.Send("ok")
+		})
+	}
+	c.last = nil // a controller is named per method
 }
 
 func (c *Core) pushMethod(method, path string, handlers ...func(*Ctx)) {
@@ -313,10 +474,10 @@ func (c *Core) pushMethod(method, path string, handlers ...func(*Ctx)) {
 	}
 
 	original := path
-	path = strings.ToLower(path)
-	if len(path) > 1 {
-		path = strings.TrimRight(path, "/")
+	if len(original) > 1 && !c.strictSlash() {
+		original = strings.TrimRight(original, "/")
 	}
+	path = c.routePath(path)
 	var isGet = method == "GET"
 	var isMiddleware = method == "USE"
 	if isMiddleware || method == "ALL" {
@@ -338,8 +499,10 @@ func (c *Core) pushMethod(method, path string, handlers ...func(*Ctx)) {
 		isRegex = true
 		Regexp = regex
 	}
+	c.last = make([]*Route, 0, len(handlers))
+	c.chains++
 	for i := range handlers {
-		c.routes = append(c.routes, &Route{
+		c.addRoute(&Route{
 			isGet:        isGet,
 			isMiddleware: isMiddleware,
 			isStar:       isStar,
@@ -350,10 +513,44 @@ func (c *Core) pushMethod(method, path string, handlers ...func(*Ctx)) {
 			Params:       Params,
 			Regexp:       Regexp,
 			Handler:      handlers[i],
+			original:     original,
+			handler:      funcName(handlers[i]),
 		})
 	}
 }
 
+// routePath the path a route matches, lowercased unless CaseSensitive,
+// without trailing slash unless StrictRouting or RedirectSlash.
+func (c *Core) routePath(path string) string {
+	if !c.CaseSensitive {
+		path = lowerPath(path)
+	}
+	if len(path) > 1 && !c.strictSlash() {
+		path = strings.TrimRight(path, "/")
+	}
+	return path
+}
+
+// strictSlash routes and requests keep the trailing slash.
+func (c *Core) strictSlash() bool {
+	return c.StrictRouting || c.RedirectSlash
+}
+
+// addRoute append route and insert it into the tree.
+func (c *Core) addRoute(route *Route) {
+	route.pos = len(c.routes)
+	route.chain = c.chains
+	if route.app == nil {
+		route.app = c
+	}
+	c.routes = append(c.routes, route)
+	c.last = append(c.last, route)
+	c.tree.add(route, c.strictSlash())
+	for _, fn := range c.hooks.route {
+		fn(route)
+	}
+}
+
 // Build Initialize
 func (c *Core) Build() error {
 	c.Server = c.newServer()
@@ -368,11 +565,7 @@ func (c *Core) Build() error {
 		}
 	}
 
-	if c.ViewEngine != nil {
-		if err := c.ViewEngine.Load(); err != nil {
-			log.Fatalf("View builder %v", err)
-		}
-	}
+	c.loadViews()
 	return nil
 }
 
@@ -395,6 +588,63 @@ func NewError(code int, message ...string) *Error {
 	return e
 }
 
+// handleError send err with the ErrorHandler of c,
+// mounted and host apps without one use the handler of their parent.
+func (c *Core) handleError(ctx *Ctx, err error) {
+	for app := c; app != nil; app = app.parent {
+		if app.Options.ErrorHandler != nil {
+			app.Options.ErrorHandler(ctx, err)
+			return
+		}
+	}
+	DefaultErrorHandler(ctx, err)
+}
+
+// DefaultErrorHandler send *Error code and message,
+// *ValidationError is 422 with the failing fields, json goes through Options.Envelope if set,
+// other errors are 500, the message shows only in Debug.
+// body is json or html depending on Accept, plain text otherwise.
+func DefaultErrorHandler(ctx *Ctx, err error) {
+	ve, invalid := err.(*ValidationError)
+	e, ok := err.(*Error)
+	switch {
+	case invalid:
+		e = NewError(422, ve.Error())
+	case !ok:
+		e = NewError(500)
+		if ctx.Core != nil && ctx.Debug {
+			e.Message = err.Error()
+		}
+	}
+	ctx.Response.SetStatusCode(e.Code)
+	switch ctx.errorFormat() {
+	case MIMEApplicationJSON:
+		if ctx.Core != nil && ctx.Envelope != nil {
+			if !ok && !invalid {
+				err = e // the message only in Debug
+			}
+			if ctx.ToJSON(nil, err) == nil {
+				return
+			}
+		}
+		var body interface{} = e
+		if invalid {
+			body = map[string]interface{}{"code": e.Code, "message": e.Message, "errors": ve.Errors}
+		}
+		if err := ctx.JSON(body); err != nil {
+			ctx.Response.SetBodyString(e.Message)
+		}
+	case MIMETextHTML:
+		title := fmt.Sprintf("%d %s", e.Code, html.EscapeString(statusMessages[e.Code]))
+		ctx.Response.Header.SetContentType(MIMETextHTMLCharsetUTF8)
+		ctx.Response.SetBodyString("<!DOCTYPE html><html><head><title>" + title + "</title></head><body><h1>" +
+			title + "</h1><p>" + html.EscapeString(e.Message) + "</p></body></html>")
+	default:
+		ctx.Response.Header.SetContentType(MIMETextPlainCharsetUTF8)
+		ctx.Response.SetBodyString(e.Message)
+	}
+}
+
 // Serve 启动
 func (c *Core) Serve(address interface{}, tlsopt ...*tls.Config) error {
 	addr, ok := address.(string)
@@ -413,10 +663,14 @@ func (c *Core) Serve(address interface{}, tlsopt ...*tls.Config) error {
 		panic(err)
 	}
 
+	if !isChild() {
+		c.printRoutes()
+	}
+
 	var ln net.Listener
 	var err error
 
-	if c.Prefork && runtime.NumCPU() > 1 && runtime.GOOS != "windows" {
+	if c.Prefork && (runtime.NumCPU() > 1 || c.PreforkChildren > 0) && runtime.GOOS != "windows" {
 		if ln, err = c.prefork(addr); err != nil {
 			return err
 		}
@@ -429,62 +683,102 @@ func (c *Core) Serve(address interface{}, tlsopt ...*tls.Config) error {
 	if len(tlsopt) > 0 {
 		ln = tls.NewListener(ln, tlsopt[0])
 	}
+	for _, fn := range c.hooks.start {
+		if err := fn(); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+	if c.HandleSignals || isChild() {
+		go c.watchSignals()
+	}
+	if isChild() {
+		notifyReady()
+	}
 	fmt.Printf("Started server on %s\n", Cyan(ln.Addr().String()))
-	return c.Server.Serve(ln)
+	err = c.Server.Serve(ln)
+	c.waitShutdown()
+	return err
 }
 
-// Sharding: https://www.nginx.com/blog/socket-sharding-nginx-release-1-9-1/
-func (c *Core) prefork(addr string) (ln net.Listener, err error) {
-	if !isChild() {
-		addr, err := net.ResolveTCPAddr("tcp", addr)
-		if err != nil {
-			return ln, err
+// Test 不监听端口, 通过内存连接执行请求, 用于单元测试
+// timeout default 1s, -1 waits forever.
+//
+//  req := httptest.NewRequest("GET", "/api/", nil)
+//  resp, err := app.Test(req)
+func (c *Core) Test(req *http.Request, timeout ...time.Duration) (*http.Response, error) {
+	to := time.Second
+	if len(timeout) > 0 {
+		to = timeout[0]
+	}
+	if c.Server == nil {
+		if err := c.Build(); err != nil {
+			return nil, err
 		}
-		tcplistener, err := net.ListenTCP("tcp", addr)
-		if err != nil {
-			return ln, err
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	defer ln.Close()
+	go c.Server.Serve(ln)
+
+	conn, err := ln.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		if err := req.Write(conn); err != nil {
+			ch <- result{err: err}
+			return
 		}
-		fl, err := tcplistener.File()
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
 		if err != nil {
-			return ln, err
-		}
-		files := []*os.File{fl}
-		childs := make([]*exec.Cmd, runtime.NumCPU()/2)
-		for i := range childs {
-			childs[i] = exec.Command(os.Args[0], append(os.Args[1:], "-prefork", "-child")...)
-			childs[i].Stdout = os.Stdout
-			childs[i].Stderr = os.Stderr
-			childs[i].ExtraFiles = files
-			if err := childs[i].Start(); err != nil {
-				return ln, err
-			}
-		}
-
-		for k := range childs {
-			if err := childs[k].Wait(); err != nil {
-				return ln, err
-			}
+			ch <- result{err: err}
+			return
 		}
-		os.Exit(0)
-	} else {
-		runtime.GOMAXPROCS(1)
-		ln, err = net.FileListener(os.NewFile(3, ""))
+		// read the body before the connection closes
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		ch <- result{resp, err}
+	}()
+
+	if to < 0 {
+		r := <-ch
+		return r.resp, r.err
+	}
+	select {
+	case r := <-ch:
+		return r.resp, r.err
+	case <-time.After(to):
+		return nil, fmt.Errorf("test: timeout after %v", to)
 	}
-	return
 }
+
 func (c *Core) handler(fctx *fasthttp.RequestCtx) {
 	ctx := assignCtx(fctx)
 	defer releaseCtx(ctx)
 	ctx.Core = c
-	ctx.path = strings.ToLower(ctx.path)
-
-	if len(ctx.path) > 1 {
+	ctx.app = c
+	if !c.CaseSensitive {
+		ctx.path = strings.ToLower(ctx.path)
+	}
+	if len(ctx.path) > 1 && !c.strictSlash() {
 		ctx.path = strings.TrimRight(ctx.path, "/")
 	}
 
 	start := time.Now()
 
-	c.nextRoute(ctx)
+	if len(c.hosts) > 0 {
+		c.matchHost(ctx)
+	}
+	ctx.app.nextRoute(ctx)
 	if c.Debug {
 		d := time.Now().Sub(start).String()
 		log.Printf("%s\t%s\t %d %s\n", Green(ctx.method), ctx.path, ctx.Response.StatusCode(), Yellow(d))
@@ -492,24 +786,105 @@ func (c *Core) handler(fctx *fasthttp.RequestCtx) {
 }
 
 func (c *Core) nextRoute(ctx *Ctx) {
-	rlen := len(c.routes) - 1
-	for ctx.index < rlen {
-		ctx.index++
-		route := c.routes[ctx.index]
-		match, values := route.matchRoute(ctx.method, ctx.path)
-		if match {
-			ctx.Route = route
-			ctx.values = values
-			route.Handler(ctx)
-			if c.ETag {
-				setETag(ctx, ctx.Response.Body(), false)
+	// method override or path rewrite needs a new lookup
+	if !ctx.matched || ctx.matchMethod != ctx.method || ctx.matchPath != ctx.path {
+		c.tree.lookup(ctx, ctx.method, ctx.path, c.strictSlash())
+		ctx.matched = true
+		ctx.matchMethod = ctx.method
+		ctx.matchPath = ctx.path
+		ctx.cursor = 0
+	}
+	for ctx.cursor < len(ctx.matches) {
+		m := ctx.matches[ctx.cursor]
+		ctx.cursor++
+		if m.route.pos <= ctx.index {
+			continue
+		}
+		// a matched host runs only the own middleware of c first
+		if ctx.host != nil && (!m.route.isMiddleware || m.route.app != c) {
+			continue
+		}
+		// group middleware of the route a handler falls through to ran already
+		if m.route.once != 0 {
+			if ctx.hasRun(m.route.once) {
+				continue
 			}
-			return
+			ctx.ran = append(ctx.ran, m.route.once)
+		}
+		ctx.index = m.route.pos
+		ctx.Route = m.route
+		ctx.values = ctx.pvalues[m.start:m.end]
+		// handlers of a mounted app see its options
+		app := m.route.app
+		ctx.Core = app
+		m.route.Handler(ctx)
+		if app.ETag && !ctx.Response.IsBodyStream() {
+			setETag(ctx, ctx.Response.Body(), false)
 		}
+		return
+	}
+	if ctx.host != nil {
+		host := ctx.host
+		ctx.host = nil
+		ctx.app = host
+		ctx.matched = false
+		ctx.index = -1
+		host.nextRoute(ctx)
+		return
+	}
+	ctx.Core = c.owner(ctx.path)
+	if !ctx.endpoint() && (c.redirectSlash(ctx) || c.methodNotAllowed(ctx)) {
+		return
+	}
+	if !ctx.hasBody() { // send a 404
+		ctx.handleError(ctx, NewError(404))
+	}
+}
+
+// methodNotAllowed answers OPTIONS or 405 when the path
+// is registered for other methods.
+func (c *Core) methodNotAllowed(ctx *Ctx) bool {
+	allowed := c.tree.allowed(ctx.path, c.strictSlash())
+	if len(allowed) == 0 {
+		return false
+	}
+	// OPTIONS is always answered, explicit handlers already matched.
+	allowed = append(allowed, MethodOptions)
+	ctx.Set(HeaderAllow, strings.Join(allowed, ", "))
+	if ctx.method == MethodOptions {
+		ctx.Response.SetStatusCode(204)
+		return true
+	}
+	ctx.handleError(ctx, NewError(405))
+	return true
+}
+
+// redirectSlash redirects to the path with or without the trailing slash
+// when a route of the method matches it.
+func (c *Core) redirectSlash(ctx *Ctx) bool {
+	if !c.RedirectSlash || ctx.path == "/" {
+		return false
+	}
+	toggle := func(p string) string {
+		if strings.HasSuffix(p, "/") {
+			return strings.TrimRight(p, "/")
+		}
+		return p + "/"
+	}
+	if !c.tree.has(ctx.method, toggle(ctx.path), true) {
+		return false
+	}
+	// a leading // or /\ is another host for browsers
+	location := "/" + strings.TrimLeft(toggle(getString(ctx.URI().PathOriginal())), "/\\")
+	if q := ctx.URI().QueryString(); len(q) > 0 {
+		location += "?" + getString(q)
 	}
-	if len(ctx.RequestCtx.Response.Body()) == 0 { // send a 404
-		ctx.SendStatus(404)
+	code := 301
+	if ctx.method != MethodGet && ctx.method != MethodHead {
+		code = 308
 	}
+	ctx.Redirect(location, code)
+	return true
 }
 
 func (c *Core) newServer() *fasthttp.Server {
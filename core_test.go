@@ -0,0 +1,131 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// serve run a request through the handler of app
+func serve(app *Core, method, uri string) *fasthttp.Response {
+	return serveHost(app, "example.com", uri, method)
+}
+
+// serveHost run a GET or method request for host
+func serveHost(app *Core, host, uri string, method ...string) *fasthttp.Response {
+	req := newRequest(MethodGet, uri, HeaderHost, host)
+	if len(method) > 0 {
+		req.Header.SetMethod(method[0])
+	}
+	return serveRequest(app, req)
+}
+
+// newRequest request of example.com with header key, value pairs
+func newRequest(method, uri string, headers ...string) *fasthttp.Request {
+	req := new(fasthttp.Request)
+	req.Header.SetMethod(method)
+	req.Header.SetHost("example.com")
+	req.SetRequestURI(uri)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	return req
+}
+
+// serveRequest run req through the handler of app
+func serveRequest(app *Core, req *fasthttp.Request) *fasthttp.Response {
+	fctx := new(fasthttp.RequestCtx)
+	req.CopyTo(&fctx.Request)
+	app.handler(fctx)
+	return &fctx.Response
+}
+
+// expectFatal run fn in a child process of the test and check it stops by log.Fatalf with msg.
+func expectFatal(t *testing.T, msg string, fn func()) {
+	t.Helper()
+	if os.Getenv("WEB_TEST_FATAL") == t.Name() {
+		fn()
+		os.Exit(0)
+	}
+	names := strings.Split(t.Name(), "/")
+	for i := range names {
+		names[i] = "^" + regexp.QuoteMeta(names[i]) + "$"
+	}
+	cmd := exec.Command(os.Args[0], "-test.run="+strings.Join(names, "/"))
+	cmd.Env = append(os.Environ(), "WEB_TEST_FATAL="+t.Name())
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); !ok || !strings.Contains(string(out), msg) {
+		t.Errorf("want log.Fatalf %q, got %v:\n%s", msg, err, out)
+	}
+}
+
+func TestRedirectSlash(t *testing.T) {
+	app := New(&Options{RedirectSlash: true})
+	app.Get("/:page", func(c *Ctx) { c.Send(c.Params("page")) })
+	app.Post("/form", func(c *Ctx) { c.Send("form") })
+	app.Get("/docs/b/", func(c *Ctx) { c.Send("b") })
+	app.Build()
+
+	cases := []struct {
+		method, uri string
+		code        int
+		location    string
+	}{
+		{MethodGet, "/about/", 301, "/about"},
+		{MethodGet, "/about/?a=1", 301, "/about?a=1"},
+		{MethodPost, "/form/", 308, "/form"},
+		{MethodGet, "/docs/b/", 200, ""}, // the registered form is canonical
+		{MethodGet, "/docs/b", 301, "/docs/b/"},
+		{MethodGet, "//evil.com/", 301, "/evil.com"},
+		{MethodGet, "///evil.com/", 301, "/evil.com"},
+		{MethodGet, "/\\evil.com/", 301, "/evil.com"},
+	}
+	for _, tc := range cases {
+		resp := serve(app, tc.method, tc.uri)
+		location := string(resp.Header.Peek(HeaderLocation))
+		if resp.StatusCode() != tc.code || location != tc.location {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.uri, resp.StatusCode(), location, tc.code, tc.location)
+		}
+		if strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\") {
+			t.Errorf("%s %s: open redirect to %q", tc.method, tc.uri, location)
+		}
+	}
+}
+
+func TestCoreTest(t *testing.T) {
+	app := New()
+	app.Get("/hello", func(c *Ctx) { c.Send("hello " + c.Query("name")) })
+	app.Get("/slow", func(c *Ctx) {
+		time.Sleep(100 * time.Millisecond)
+		c.Send("slow")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/hello?name=joe", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "hello joe" {
+		t.Errorf("got %d %q", resp.StatusCode, body)
+	}
+
+	if _, err := app.Test(httptest.NewRequest(MethodGet, "/slow", nil), 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("timeout: %v", err)
+	}
+
+	resp, err = app.Test(httptest.NewRequest(MethodGet, "/slow", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	if string(body) != "slow" {
+		t.Errorf("-1: got %q", body)
+	}
+}
@@ -0,0 +1,130 @@
+package web
+
+// MIMEApplicationProblemJSON RFC 7807 problem details
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Envelope 响应数据的包装格式, Options.Envelope
+// ToJSON and typed handler results are wrapped by it,
+// with Options.Envelope set DefaultErrorHandler sends json errors through it too.
+type Envelope interface {
+	// Wrap data or err with the meta of Ctx.SetMeta, returns the status code
+	// (0 keeps the response status), the content type ("" is application/json)
+	// and the body encoded by the json codec.
+	Wrap(c *Ctx, data interface{}, err error) (status int, contentType string, body interface{})
+}
+
+// LegacyEnvelope {"status": true, "result": data, "msg": "success"}, the default
+// errors set status false and msg, validation errors add "errors", meta adds "meta".
+type LegacyEnvelope struct{}
+
+// Wrap implements Envelope.
+func (LegacyEnvelope) Wrap(c *Ctx, data interface{}, err error) (int, string, interface{}) {
+	body := map[string]interface{}{
+		"status": true,
+		"result": data,
+		"msg":    "success",
+	}
+	if err != nil {
+		body["status"] = false
+		body["msg"] = err.Error()
+		if ve, ok := err.(*ValidationError); ok {
+			body["errors"] = ve.Errors
+		}
+	}
+	if meta := c.Meta(); len(meta) > 0 {
+		body["meta"] = meta
+	}
+	return 0, "", body
+}
+
+// BareEnvelope data as is, errors as {"code", "message", "errors"} with their status,
+// meta is not sent.
+type BareEnvelope struct{}
+
+// Wrap implements Envelope.
+func (BareEnvelope) Wrap(c *Ctx, data interface{}, err error) (int, string, interface{}) {
+	if err == nil {
+		return 0, "", data
+	}
+	e, ve := errorStatus(c, err)
+	body := map[string]interface{}{"code": e.Code, "message": e.Message}
+	if ve != nil {
+		body["errors"] = ve.Errors
+	}
+	return e.Code, "", body
+}
+
+// ProblemEnvelope data as is, errors as RFC 7807 application/problem+json:
+//
+//  {"type": "about:blank", "title": "Not Found", "status": 404, "detail": "user 7 not found"}
+//
+// validation errors add "errors", meta is added as extension members.
+type ProblemEnvelope struct {
+	// Type returns the problem type URI of a status code, default about:blank
+	Type func(code int) string
+}
+
+// Wrap implements Envelope.
+func (p ProblemEnvelope) Wrap(c *Ctx, data interface{}, err error) (int, string, interface{}) {
+	if err == nil {
+		return 0, "", data
+	}
+	e, ve := errorStatus(c, err)
+	body := make(map[string]interface{})
+	for k, v := range c.Meta() {
+		body[k] = v
+	}
+	body["type"] = "about:blank"
+	if p.Type != nil {
+		body["type"] = p.Type(e.Code)
+	}
+	body["title"] = statusMessages[e.Code]
+	body["status"] = e.Code
+	if e.Message != "" && e.Message != statusMessages[e.Code] {
+		body["detail"] = e.Message
+	}
+	if ve != nil {
+		body["errors"] = ve.Errors
+	}
+	return e.Code, MIMEApplicationProblemJSON, body
+}
+
+// errorStatus *Error of err as DefaultErrorHandler sends it:
+// validation errors are 422, other errors 500 with the message only in Debug.
+func errorStatus(c *Ctx, err error) (*Error, *ValidationError) {
+	switch e := err.(type) {
+	case *Error:
+		return e, nil
+	case *ValidationError:
+		return NewError(422, e.Error()), e
+	}
+	e := NewError(500)
+	if c.Core != nil && c.Debug {
+		e.Message = err.Error()
+	}
+	return e, nil
+}
+
+// envelope the envelope of Options, LegacyEnvelope if none.
+func (c *Ctx) envelope() Envelope {
+	if c.Core != nil && c.Envelope != nil {
+		return c.Envelope
+	}
+	return LegacyEnvelope{}
+}
+
+// SetMeta 设置响应元数据, 由 Envelope 包装 (分页, request id ...)
+//
+//  c.SetMeta("page", 2)
+//  c.ToJSON(users, nil)
+func (c *Ctx) SetMeta(k string, v interface{}) {
+	if c.meta == nil {
+		c.meta = make(map[string]interface{})
+	}
+	c.meta[k] = v
+}
+
+// Meta 响应元数据 of SetMeta
+func (c *Ctx) Meta() map[string]interface{} {
+	return c.meta
+}
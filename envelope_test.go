@@ -0,0 +1,41 @@
+package web
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEnvelopeHidesErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		envelope Envelope
+		body     string
+	}{
+		{"legacy", LegacyEnvelope{}, `{"msg":"Internal Server Error","result":null,"status":false}`},
+		{"bare", BareEnvelope{}, `{"code":500,"message":"Internal Server Error"}`},
+		{"problem", ProblemEnvelope{}, `{"status":500,"title":"Internal Server Error","type":"about:blank"}`},
+	}
+	for _, tc := range cases {
+		app := New(&Options{Envelope: tc.envelope})
+		app.Get("/fail", func(c *Ctx) error { return errors.New("db password wrong") })
+		app.Get("/gone", func(c *Ctx) error { return NewError(410, "user 7 gone") })
+		app.Build()
+
+		resp := serveRequest(app, newRequest(MethodGet, "/fail", HeaderAccept, MIMEApplicationJSON))
+		if resp.StatusCode() != 500 || string(resp.Body()) != tc.body {
+			t.Errorf("%s: got %d %s, want 500 %s", tc.name, resp.StatusCode(), resp.Body(), tc.body)
+		}
+		resp = serveRequest(app, newRequest(MethodGet, "/gone", HeaderAccept, MIMEApplicationJSON))
+		if resp.StatusCode() != 410 || !strings.Contains(string(resp.Body()), "user 7 gone") {
+			t.Errorf("%s: *Error got %d %s", tc.name, resp.StatusCode(), resp.Body())
+		}
+	}
+
+	app := New(&Options{Envelope: LegacyEnvelope{}, Debug: true})
+	app.Get("/fail", func(c *Ctx) error { return errors.New("db password wrong") })
+	app.Build()
+	if resp := serveRequest(app, newRequest(MethodGet, "/fail", HeaderAccept, MIMEApplicationJSON)); !strings.Contains(string(resp.Body()), "db password wrong") {
+		t.Errorf("debug: got %s", resp.Body())
+	}
+}
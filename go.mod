@@ -7,13 +7,15 @@ require (
 	github.com/aymerick/raymond v2.0.2+incompatible
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fasthttp/websocket v1.4.3
+	github.com/fxamacker/cbor/v2 v2.2.0
 	github.com/gorilla/schema v1.2.0
 	github.com/klauspost/compress v1.11.3 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/savsgio/gotils v0.0.0-20200909101946-939aa3fc74fb // indirect
 	github.com/valyala/fasthttp v1.18.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	github.com/xs23933/uid v0.0.5
 	golang.org/x/text v0.3.4
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
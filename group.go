@@ -0,0 +1,176 @@
+package web
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Group 路由分组
+// routes registered in a group share the prefix,
+// the group middleware runs only for these routes and once per request,
+// also when a handler falls through to another route of the group by Next.
+type Group struct {
+	core       *Core
+	prefix     string
+	middleware []func(*Ctx)
+	once       []uint64 // Route.once of middleware
+}
+
+// Group create a route group with prefix and middleware.
+func (c *Core) Group(prefix string, middleware ...func(*Ctx)) *Group {
+	return &Group{
+		core:       c,
+		prefix:     joinPath("", prefix),
+		middleware: chain(nil, middleware...),
+		once:       onceIDs(nil, len(middleware)),
+	}
+}
+
+// Group create a nested group, it inherits prefix and middleware.
+func (g *Group) Group(prefix string, middleware ...func(*Ctx)) *Group {
+	return &Group{
+		core:       g.core,
+		prefix:     joinPath(g.prefix, prefix),
+		middleware: chain(g.middleware, middleware...),
+		once:       onceIDs(g.once, len(middleware)),
+	}
+}
+
+// Prefix returns the group prefix.
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Use adds middleware to the group,
+// a handle registers the controller inside the group,
+// with a path it registers a middleware route under the group prefix,
+// matching whole segments: /admin/path runs for /admin/path/x, not /admin/pathology.
+func (g *Group) Use(args ...interface{}) *Group {
+	path, handlers, hands := parseArgs("Use", args)
+	for _, hand := range hands {
+		g.core.buildHands(hand, g.prefix, g.middleware, g.once)
+	}
+	if len(handlers) == 0 {
+		return g
+	}
+	if path == "" {
+		g.middleware = chain(g.middleware, handlers...)
+		g.once = onceIDs(g.once, len(handlers))
+		g.core.last = nil
+		return g
+	}
+	g.core.pushMethod("USE", joinRoute(g.prefix, path), chain(g.middleware, handlers...)...)
+	g.core.nameHandlers(args)
+	markOnce(g.core.last, g.once)
+	for _, r := range g.core.last {
+		r.segment = true
+	}
+	return g
+}
+
+// Static serve static files under the group prefix.
+func (g *Group) Static(prefix, root string, config ...Static) *Group {
+	g.core.regStatic(joinRoute(g.prefix, prefix), root, g.middleware, config...)
+	markOnce(g.core.last, g.once)
+	return g
+}
+
+// Get registers a route for GET methods.
+func (g *Group) Get(args ...interface{}) *Group {
+	return g.Add(MethodGet, args...)
+}
+
+// Post registers a route for POST methods.
+func (g *Group) Post(args ...interface{}) *Group {
+	return g.Add(MethodPost, args...)
+}
+
+// Put registers a route for PUT methods.
+func (g *Group) Put(args ...interface{}) *Group {
+	return g.Add(MethodPut, args...)
+}
+
+// Delete registers a route for DELETE methods.
+func (g *Group) Delete(args ...interface{}) *Group {
+	return g.Add(MethodDelete, args...)
+}
+
+// Patch registers a route for PATCH methods.
+func (g *Group) Patch(args ...interface{}) *Group {
+	return g.Add(MethodPatch, args...)
+}
+
+// Head registers a route for HEAD methods.
+func (g *Group) Head(args ...interface{}) *Group {
+	return g.Add(MethodHead, args...)
+}
+
+// Opts registers a route for OPTIONS methods.
+func (g *Group) Opts(args ...interface{}) *Group {
+	return g.Add(MethodOptions, args...)
+}
+
+// Connect registers a route for CONNECT methods.
+func (g *Group) Connect(args ...interface{}) *Group {
+	return g.Add(MethodConnect, args...)
+}
+
+// Trace registers a route for TRACE methods.
+func (g *Group) Trace(args ...interface{}) *Group {
+	return g.Add(MethodTrace, args...)
+}
+
+// All registers a route for all methods.
+func (g *Group) All(args ...interface{}) *Group {
+	return g.Add("ALL", args...)
+}
+
+// Add registers a route for the given method under the group prefix.
+func (g *Group) Add(method string, args ...interface{}) *Group {
+	method = strings.ToUpper(method)
+	path, handlers, hands := parseArgs(method, args)
+	if len(hands) > 0 { // 不需要综合注册
+		for _, hand := range hands {
+			g.core.buildHands(hand, g.prefix, g.middleware, g.once)
+		}
+		return g
+	}
+
+	g.core.pushMethod(method, joinRoute(g.prefix, path), chain(g.middleware, handlers...)...)
+	g.core.nameHandlers(args)
+	markOnce(g.core.last, g.once)
+	return g
+}
+
+// Name names the routes registered by the previous call, see Core.Name.
+func (g *Group) Name(name string) *Group {
+	g.core.Name(name)
+	return g
+}
+
+// chain returns a new slice of middleware followed by handlers.
+func chain(middleware []func(*Ctx), handlers ...func(*Ctx)) []func(*Ctx) {
+	hs := make([]func(*Ctx), 0, len(middleware)+len(handlers))
+	hs = append(hs, middleware...)
+	return append(hs, handlers...)
+}
+
+// onceID last Route.once id given
+var onceID uint64
+
+// onceIDs returns a copy of ids followed by n new ids.
+func onceIDs(ids []uint64, n int) []uint64 {
+	out := make([]uint64, len(ids), len(ids)+n)
+	copy(out, ids)
+	for i := 0; i < n; i++ {
+		out = append(out, atomic.AddUint64(&onceID, 1))
+	}
+	return out
+}
+
+// markOnce set the Route.once ids of the routes of the leading handlers.
+func markOnce(routes []*Route, ids []uint64) {
+	for i := range ids {
+		routes[i].once = ids[i]
+	}
+}
@@ -0,0 +1,64 @@
+package web
+
+import "testing"
+
+func TestGroupStrictRouting(t *testing.T) {
+	app := New(&Options{StrictRouting: true})
+	g := app.Group("/g")
+	g.Get("/foo/", func(c *Ctx) { c.Send("foo/") })
+	g.Get("/bar", func(c *Ctx) { c.Send("bar") })
+	g.Use("/mw/", func(c *Ctx) { c.Send("mw/") })
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		code int
+	}{
+		{"/g/foo/", 200},
+		{"/g/foo", 404},
+		{"/g/bar", 200},
+		{"/g/bar/", 404},
+		{"/g/mw/x", 200},
+	}
+	for _, tc := range cases {
+		if code := serve(app, MethodGet, tc.uri).StatusCode(); code != tc.code {
+			t.Errorf("GET %s: got %d, want %d", tc.uri, code, tc.code)
+		}
+	}
+}
+
+func TestGroupMiddlewareOnce(t *testing.T) {
+	step := func(s string) func(*Ctx) {
+		return func(c *Ctx) {
+			c.Response.AppendBodyString(s + ";")
+			c.Next()
+		}
+	}
+	app := New()
+	g := app.Group("/admin", step("auth"))
+	g.Use("/path", step("path"))
+	g.Get("/users", step("users"))
+	g.Get("/:page", step("page"))
+	sub := g.Group("/sub", step("sub"))
+	sub.Get("/x", step("x"))
+	g.Get("/sub/*", step("rest"))
+	app.Get("/administrator", step("administrator"))
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		body string
+	}{
+		{"/admin/users", "auth;users;page;"},
+		{"/admin/path", "auth;path;page;"},
+		{"/admin/path/x", "auth;path;"},
+		{"/admin/pathology", "auth;page;"},
+		{"/admin/sub/x", "auth;sub;x;rest;"},
+		{"/administrator", "administrator;"},
+	}
+	for _, tc := range cases {
+		if body := string(serve(app, MethodGet, tc.uri).Body()); body != tc.body {
+			t.Errorf("GET %s: got %q, want %q", tc.uri, body, tc.body)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package web
 
+import (
+	"log"
+	"reflect"
+	"strings"
+)
+
 // Handler 基础分类
 type Handler struct {
 	prefix string
@@ -11,6 +17,7 @@ func (h *Handler) SetPrefix(prefix string) {
 }
 
 // Preload 预处理使用 必须配置 Next结尾
+// runs before every route of the controller only, once per request.
 func (h *Handler) Preload(c *Ctx) {
 	c.Next()
 }
@@ -31,3 +38,193 @@ type handle interface {
 	// 每次调用时预处理
 	Preload(*Ctx)
 }
+
+// RouteDescriptor 控制器方法的显式路由
+type RouteDescriptor struct {
+	Handler    string       // controller method name
+	Method     string       // GET, POST ... or ALL
+	Path       string       // relative to the controller prefix
+	Name       string       // route name, default Controller.Method
+	Middleware []func(*Ctx) // runs before the handler
+}
+
+// methodMiddleware middleware by method name: {"DeleteParam": {auth}}
+type methodMiddleware interface {
+	Middleware() map[string][]func(*Ctx)
+}
+
+// routeTable explicit routes by method name: {"ListOrders": "GET /users/:id/orders"}
+type routeTable interface {
+	Routes() map[string]string
+}
+
+// routeDescriptors explicit routes with names and middleware.
+type routeDescriptors interface {
+	Routes() []RouteDescriptor
+}
+
+// handRoutes collect the explicit routes of a controller by method name,
+// nil if it has none. Malformed entries stop the registration.
+func handRoutes(hand handle, ctlName string) map[string]RouteDescriptor {
+	var descs []RouteDescriptor
+	switch h := hand.(type) {
+	case routeTable:
+		for name, route := range h.Routes() {
+			parts := strings.Fields(route)
+			if len(parts) != 2 {
+				log.Fatalf("Router: %s.%s route %q must be \"VERB /path\"", ctlName, name, route)
+			}
+			descs = append(descs, RouteDescriptor{Handler: name, Method: parts[0], Path: parts[1]})
+		}
+	case routeDescriptors:
+		descs = h.Routes()
+	default:
+		return nil
+	}
+	table := make(map[string]RouteDescriptor, len(descs))
+	for _, desc := range descs {
+		desc.Method = strings.ToUpper(desc.Method)
+		if _, ok := methodINT[desc.Method]; !ok && desc.Method != "ALL" {
+			log.Fatalf("Router: %s.%s unknown method %q", ctlName, desc.Handler, desc.Method)
+		}
+		if _, ok := table[desc.Handler]; ok {
+			log.Fatalf("Router: %s.%s listed twice", ctlName, desc.Handler)
+		}
+		table[desc.Handler] = desc
+	}
+	return table
+}
+
+// checkConflict fail if method and path are already routed
+// by this controller or by a route registered before,
+// param names do not matter: /u/:id and /u/:uid conflict.
+func (c *Core) checkConflict(seen map[string]string, desc RouteDescriptor, handler string) {
+	method := desc.Method
+	if method == "ALL" {
+		method = "*"
+	}
+	p := routeKey(c.routePath(desc.Path))
+	key := method + " " + p
+	if other, ok := seen[key]; ok {
+		log.Fatalf("Router: %s and %s both route %s %s", other, handler, desc.Method, desc.Path)
+	}
+	seen[key] = handler
+	for _, r := range c.routes {
+		if !r.isMiddleware && r.Method == method && routeKey(r.Path) == p {
+			log.Fatalf("Router: %s routes %s %s, already registered", handler, desc.Method, desc.Path)
+		}
+	}
+}
+
+// routeKey path without param names: /u/:id<int>? is /u/:<int>?
+func routeKey(path string) string {
+	if !strings.Contains(path, ":") {
+		return path
+	}
+	segments := splitPath(path)
+	for i, s := range segments {
+		if len(s) == 0 || s[0] != ':' {
+			continue
+		}
+		_, cons, optional := paramSegment(s)
+		s = ":"
+		if cons != "" {
+			s += "<" + cons + ">"
+		}
+		if optional {
+			s += "?"
+		}
+		segments[i] = s
+	}
+	return strings.Join(segments, "/")
+}
+
+var (
+	ctxType   = reflect.TypeOf((*Ctx)(nil))
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typedHandler adapt handlers binding a request struct and returning a result:
+//
+//  func(*Ctx) (interface{}, error)
+//  func(*Ctx, *CreateUserReq) (*User, error)
+//  func(*Ctx, *CreateUserReq) error
+//
+// the request struct is filled by Ctx.Bind, its errors go to the error handler,
+// the result and error are sent by Ctx.respond.
+// Signatures are checked once here, not per request.
+func typedHandler(h interface{}) (func(*Ctx), bool) {
+	if h == nil {
+		return nil, false
+	}
+	fn := reflect.ValueOf(h)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.IsVariadic() || t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != ctxType {
+		return nil, false
+	}
+	if t.NumOut() < 1 || t.NumOut() > 2 || t.Out(t.NumOut()-1) != errorType {
+		return nil, false
+	}
+	if t.NumIn() == 1 && t.NumOut() == 1 { // func(*Ctx) error, see toHandler
+		return nil, false
+	}
+	var req reflect.Type
+	isPtr := false
+	if t.NumIn() == 2 {
+		req = t.In(1)
+		if isPtr = req.Kind() == reflect.Ptr; isPtr {
+			req = req.Elem()
+		}
+		if req.Kind() != reflect.Struct {
+			return nil, false
+		}
+		if _, err := cachedFields(req); err != nil {
+			log.Fatalf("Router: %v", err)
+		}
+	}
+	result := t.NumOut() == 2
+
+	return func(c *Ctx) {
+		args := make([]reflect.Value, 1, 2)
+		args[0] = reflect.ValueOf(c)
+		if req != nil {
+			v := reflect.New(req)
+			if err := c.Bind(v.Interface()); err != nil {
+				c.Next(err)
+				return
+			}
+			if !isPtr {
+				v = v.Elem()
+			}
+			args = append(args, v)
+		}
+		out := fn.Call(args)
+		err, _ := out[len(out)-1].Interface().(error)
+		if !result {
+			if err != nil {
+				c.Next(err)
+			}
+			return
+		}
+		c.respond(out[0].Interface(), err)
+	}, true
+}
+
+// respond send the result of a typed handler,
+// nothing is sent if the handler already wrote a body and returned nothing.
+// Errors get the status and message DefaultErrorHandler would send.
+func (c *Ctx) respond(data interface{}, err error) {
+	if data == nil && err == nil && c.hasBody() {
+		return
+	}
+	if err != nil {
+		e, ve := errorStatus(c, err)
+		c.Response.SetStatusCode(e.Code)
+		if ve == nil {
+			err = e
+		}
+	}
+	if err := c.ToJSON(data, err); err != nil {
+		c.Next(err)
+	}
+}
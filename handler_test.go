@@ -0,0 +1,162 @@
+package web
+
+import (
+	"errors"
+	"testing"
+)
+
+type typedReq struct {
+	Name string `query:"name" validate:"required"`
+}
+
+type typedCtl struct{ Handler }
+
+func (h *typedCtl) Init()                               { h.SetPrefix("/t") }
+func (typedCtl) GetOk(c *Ctx) (interface{}, error)      { return 1, nil }
+func (typedCtl) GetFail(c *Ctx) (interface{}, error)    { return nil, errors.New("db password wrong") }
+func (typedCtl) GetGone(c *Ctx) (interface{}, error)    { return nil, NewError(410, "gone") }
+func (typedCtl) GetInvalid(c *Ctx, req *typedReq) error { return nil }
+
+func TestTypedHandlerErrors(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		app := New(&Options{Debug: debug})
+		app.Use(new(typedCtl))
+		app.Build()
+		fail := `{"msg":"Internal Server Error","result":null,"status":false}`
+		if debug {
+			fail = `{"msg":"db password wrong","result":null,"status":false}`
+		}
+		cases := []struct {
+			uri  string
+			code int
+			body string
+		}{
+			{"/t/ok", 200, `{"msg":"success","result":1,"status":true}`},
+			{"/t/fail", 500, fail},
+			{"/t/gone", 410, `{"msg":"gone","result":null,"status":false}`},
+			{"/t/invalid", 422, ""},
+		}
+		for _, tc := range cases {
+			resp := serve(app, MethodGet, tc.uri)
+			if resp.StatusCode() != tc.code || tc.body != "" && string(resp.Body()) != tc.body {
+				t.Errorf("debug %v GET %s: got %d %s, want %d %s", debug, tc.uri, resp.StatusCode(), resp.Body(), tc.code, tc.body)
+			}
+		}
+	}
+}
+
+type preloadCtl struct{ Handler }
+
+func (h *preloadCtl) Init() { h.SetPrefix("/u") }
+func (preloadCtl) Preload(c *Ctx) {
+	c.Response.AppendBodyString("pre;")
+	c.Next()
+}
+func (preloadCtl) GetList(c *Ctx) {
+	c.Response.AppendBodyString("list;")
+	c.Next()
+}
+func (preloadCtl) GetParam(c *Ctx) {
+	c.Response.AppendBodyString("param=" + c.Params("param") + ";")
+}
+
+func TestPreloadOnce(t *testing.T) {
+	app := New()
+	app.Use(new(preloadCtl))
+	app.Get("/other", func(c *Ctx) { c.Send("other;") })
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		body string
+	}{
+		{"/u/list", "pre;list;param=list;"},
+		{"/u/7", "pre;param=7;"},
+		{"/other", "other;"},
+	}
+	for _, tc := range cases {
+		if body := string(serve(app, MethodGet, tc.uri).Body()); body != tc.body {
+			t.Errorf("GET %s: got %q, want %q", tc.uri, body, tc.body)
+		}
+	}
+}
+
+type paramCtl struct{ Handler }
+
+func (paramCtl) Routes() map[string]string { return map[string]string{"Show": "GET /:id"} }
+func (paramCtl) GetParam(c *Ctx)           {}
+func (paramCtl) Show(c *Ctx)               {}
+
+type listCtl struct{ Handler }
+
+func (h *listCtl) Init()       { h.SetPrefix("/c") }
+func (listCtl) GetList(c *Ctx) {}
+
+type tableCtl struct {
+	Handler
+	routes map[string]string
+	mw     map[string][]func(*Ctx)
+}
+
+func (h tableCtl) Routes() map[string]string           { return h.routes }
+func (h tableCtl) Middleware() map[string][]func(*Ctx) { return h.mw }
+func (tableCtl) Show(c *Ctx)                           {}
+func (tableCtl) Helper(n int) int                      { return n }
+
+type descCtl struct{ Handler }
+
+func (descCtl) Routes() []RouteDescriptor {
+	return []RouteDescriptor{{Handler: "Show", Method: "GET", Path: "/a"}, {Handler: "Show", Method: "GET", Path: "/b"}}
+}
+func (descCtl) Show(c *Ctx) {}
+
+func TestRouteConflicts(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  string
+		fn   func(app *Core)
+	}{
+		{"param names", "paramCtl.GetParam and paramCtl.Show both route GET /:id", func(app *Core) {
+			app.Use(new(paramCtl))
+		}},
+		{"registered before", "listCtl.GetList routes GET /c/list, already registered", func(app *Core) {
+			app.Get("/c/list", func(c *Ctx) {})
+			app.Use(new(listCtl))
+		}},
+		{"param route before", "already registered", func(app *Core) {
+			app.Get("/:page", func(c *Ctx) {})
+			app.Use(&tableCtl{routes: map[string]string{"Show": "GET /:id"}})
+		}},
+		{"malformed", `tableCtl.Show route "GET" must be "VERB /path"`, func(app *Core) {
+			app.Use(&tableCtl{routes: map[string]string{"Show": "GET"}})
+		}},
+		{"unknown method", `tableCtl.Show unknown method "FETCH"`, func(app *Core) {
+			app.Use(&tableCtl{routes: map[string]string{"Show": "FETCH /x"}})
+		}},
+		{"not found", "tableCtl.Nope listed by Routes() not found", func(app *Core) {
+			app.Use(&tableCtl{routes: map[string]string{"Nope": "GET /x"}})
+		}},
+		{"not a handler", "tableCtl.Helper is not a handler", func(app *Core) {
+			app.Use(&tableCtl{routes: map[string]string{"Helper": "GET /x"}})
+		}},
+		{"middleware", "tableCtl.Nope listed by Middleware() is not a route", func(app *Core) {
+			app.Use(&tableCtl{routes: map[string]string{"Show": "GET /x"}, mw: map[string][]func(*Ctx){"Nope": nil}})
+		}},
+		{"listed twice", "descCtl.Show listed twice", func(app *Core) {
+			app.Use(new(descCtl))
+		}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			expectFatal(t, tc.msg, func() { tc.fn(New()) })
+		})
+	}
+
+	// constraints and other methods do not conflict
+	app := New()
+	app.Get("/:page<int>", func(c *Ctx) {})
+	app.Post("/:page", func(c *Ctx) {})
+	app.Use(&tableCtl{routes: map[string]string{"Show": "GET /:id"}})
+	app.Use(new(listCtl))
+}
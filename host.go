@@ -0,0 +1,137 @@
+package web
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// hostRoute app serving a host pattern.
+type hostRoute struct {
+	pattern string
+	labels  []string // lowercased, "{name}" or "*" for the first label
+	keys    []string // names of the {name} labels
+	static  int      // static labels, more static patterns are tried first
+	app     *Core
+}
+
+// Host 按域名匹配路由
+// returns the app serving pattern, routes and groups registered on it
+// only match requests for the host. Without app it shares the Options of c.
+//
+// patterns: "api.example.com", "{tenant}.example.com" (Ctx.Params("tenant")),
+// "*.example.com" (one or more labels) and "*" (every host).
+// Exact hosts are tried first, then patterns with more static labels,
+// requests matching no host are served by the routes of c.
+// The Use middleware of c (Recover, loggers, auth ...) runs first for every host,
+// routes and mounted apps of c are not served for matched hosts.
+//
+//  app.Host("api.example.com").Group("/v1").Get("/users", listUsers)
+//  app.Host("{tenant}.example.com").Use(new(TenantHandler))
+//  app.Host("*", fallback)
+func (c *Core) Host(pattern string, app ...*Core) *Core {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	for _, h := range c.hosts {
+		if h.pattern != pattern {
+			continue
+		}
+		if len(app) > 0 && app[0] != h.app {
+			log.Fatalf("Router: host %q already registered", pattern)
+		}
+		return h.app
+	}
+
+	h := &hostRoute{pattern: pattern, labels: strings.Split(pattern, ".")}
+	for i, l := range h.labels {
+		switch {
+		case l == "":
+			log.Fatalf("Router: invalid host %q", pattern)
+		case l == "*":
+			if i > 0 {
+				log.Fatalf("Router: host %q, * must be the first label", pattern)
+			}
+		case l[0] == '{' && l[len(l)-1] == '}' && len(l) > 2:
+			h.keys = append(h.keys, l[1:len(l)-1])
+		case strings.ContainsAny(l, "{}*"):
+			log.Fatalf("Router: invalid host label %q in %q", l, pattern)
+		default:
+			h.static++
+		}
+	}
+
+	if len(app) > 0 {
+		h.app = app[0]
+		if h.app == c || h.app.parent != nil {
+			log.Fatalf("Router: host %q app is already in use", pattern)
+		}
+	} else {
+		h.app = New(c.Options)
+	}
+	h.app.parent = c
+	c.hosts = append(c.hosts, h)
+	sort.SliceStable(c.hosts, func(i, j int) bool {
+		a, b := c.hosts[i], c.hosts[j]
+		if a.exact() != b.exact() {
+			return a.exact()
+		}
+		return a.static > b.static
+	})
+	return h.app
+}
+
+func (h *hostRoute) exact() bool {
+	return h.static == len(h.labels)
+}
+
+// match host against the pattern, the {name} values are appended to values.
+func (h *hostRoute) match(host []string, values []string) ([]string, bool) {
+	labels := h.labels
+	if labels[0] == "*" {
+		labels = labels[1:]
+		if len(host) <= len(labels) && h.pattern != "*" {
+			return values, false
+		}
+		host = host[len(host)-len(labels):]
+	} else if len(host) != len(labels) {
+		return values, false
+	}
+	n := len(values)
+	for i, l := range labels {
+		switch {
+		case l[0] == '{':
+			values = append(values, host[i])
+		case l != host[i]:
+			return values[:n], false
+		}
+	}
+	return values, true
+}
+
+// matchHost set the app of the first matching host, nextRoute switches to it
+// after the Use middleware of c.
+func (c *Core) matchHost(ctx *Ctx) {
+	host := strings.ToLower(ctx.Hostname())
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for _, h := range c.hosts {
+		values, ok := h.match(labels, ctx.hostValues[:0])
+		ctx.hostValues = values
+		if ok {
+			ctx.hostKeys = h.keys
+			ctx.host = h.app
+			return
+		}
+	}
+}
+
+// hostParam value of a {name} host label.
+func (c *Ctx) hostParam(k string) string {
+	for i := range c.hostKeys {
+		if c.hostKeys[i] == k && i < len(c.hostValues) {
+			return c.hostValues[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package web
+
+import "testing"
+
+func TestHostParentMiddleware(t *testing.T) {
+	app := New()
+	app.Use(func(c *Ctx) {
+		c.Set("X-Root", "1")
+		c.Next()
+	})
+	app.Get("/", func(c *Ctx) { c.Send("root") })
+	app.Host("{tenant}.example.com").Get("/", func(c *Ctx) { c.Send("tenant " + c.Params("tenant")) })
+	app.Build()
+
+	for host, body := range map[string]string{
+		"acme.example.com": "tenant acme",
+		"example.com":      "root",
+	} {
+		resp := serveHost(app, host, "/")
+		if string(resp.Body()) != body || string(resp.Header.Peek("X-Root")) != "1" {
+			t.Errorf("%s: got %q, X-Root %q", host, resp.Body(), resp.Header.Peek("X-Root"))
+		}
+	}
+	if resp := serveHost(app, "acme.example.com", "/nope"); resp.StatusCode() != 404 || string(resp.Header.Peek("X-Root")) != "1" {
+		t.Errorf("404: got %d, X-Root %q", resp.StatusCode(), resp.Header.Peek("X-Root"))
+	}
+}
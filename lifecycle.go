@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// hooks lifecycle callbacks
+type hooks struct {
+	start    []func() error
+	shutdown []func()
+	route    []func(*Route)
+}
+
+// OnStart 服务启动前调用
+// runs after the listener is ready and before serving,
+// an error stops Serve.
+func (c *Core) OnStart(fn func() error) *Core {
+	c.hooks.start = append(c.hooks.start, fn)
+	return c
+}
+
+// OnShutdown 服务关闭后调用
+// runs when Shutdown finished draining requests.
+func (c *Core) OnShutdown(fn func()) *Core {
+	c.hooks.shutdown = append(c.hooks.shutdown, fn)
+	return c
+}
+
+// OnRoute 注册路由时调用
+// routes registered before are passed at once.
+func (c *Core) OnRoute(fn func(*Route)) *Core {
+	c.hooks.route = append(c.hooks.route, fn)
+	for _, r := range c.routes {
+		fn(r)
+	}
+	return c
+}
+
+// TrackHijacked 登记被劫持的连接(websocket)
+// closeFn is called by Shutdown, call untrack when the connection ends.
+// Mounted and host apps track on the root app, which is the one shut down.
+func (c *Core) TrackHijacked(closeFn func()) (untrack func()) {
+	for c.parent != nil {
+		c = c.parent
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hijacked == nil {
+		c.hijacked = make(map[uint64]func())
+	}
+	c.hijackID++
+	id := c.hijackID
+	c.hijacked[id] = closeFn
+	return func() {
+		c.mu.Lock()
+		delete(c.hijacked, id)
+		c.mu.Unlock()
+	}
+}
+
+// Shutdown 优雅关闭
+// stops accepting connections, closes hijacked connections
+// and waits for active requests until ctx is done.
+func (c *Core) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closing != nil { // already shutting down
+		closing := c.closing
+		c.mu.Unlock()
+		select {
+		case <-closing:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	c.closing = make(chan struct{})
+	hijacked := make([]func(), 0, len(c.hijacked))
+	for _, fn := range c.hijacked {
+		hijacked = append(hijacked, fn)
+	}
+	c.mu.Unlock()
+
+	errc := make(chan error, 1)
+	go func() {
+		if c.Server == nil {
+			errc <- nil
+			return
+		}
+		errc <- c.Server.Shutdown()
+	}()
+	for _, fn := range hijacked {
+		fn()
+	}
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	for _, fn := range c.hooks.shutdown {
+		fn()
+	}
+	close(c.closing)
+	return err
+}
+
+// waitShutdown blocks until a started Shutdown finished.
+func (c *Core) waitShutdown() {
+	c.mu.Lock()
+	closing := c.closing
+	c.mu.Unlock()
+	if closing != nil {
+		<-closing
+	}
+}
+
+// watchSignals shutdown on SIGINT or SIGTERM.
+func (c *Core) watchSignals() {
+	ch := make(chan os.Signal, 1)
+	// keep notifying, a second signal must not kill the draining process
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-ch
+	timeout := c.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if c.Debug {
+		log.Printf("%v received, shutting down in %v\n", sig, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown: %v\n", err)
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownClosesHijacked(t *testing.T) {
+	app := New()
+	sub := New()
+	app.Mount("/s", sub)
+	api := app.Host("api.example.com")
+	app.Build()
+
+	closed := make(map[string]bool)
+	for name, c := range map[string]*Core{"root": app, "mount": sub, "host": api, "gone": sub} {
+		name := name
+		untrack := c.TrackHijacked(func() { closed[name] = true })
+		if name == "gone" {
+			untrack()
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"root", "mount", "host"} {
+		if !closed[name] {
+			t.Errorf("%s connection not closed", name)
+		}
+	}
+	if closed["gone"] {
+		t.Error("untracked connection closed")
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	var started, stopped int32
+	app := New()
+	app.Get("/slow", func(c *Ctx) {
+		close(entered)
+		<-release
+		c.Send("done")
+	})
+	app.OnStart(func() error {
+		atomic.AddInt32(&started, 1)
+		return nil
+	})
+	app.OnShutdown(func() { atomic.AddInt32(&stopped, 1) })
+
+	served := make(chan error, 1)
+	go func() { served <- app.Serve(addr) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		for i := 0; ; i++ { // wait for the listener
+			resp, err := client.Get("http://" + addr + "/slow")
+			if err != nil && i < 100 {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			if err != nil {
+				got <- result{err: err}
+				return
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			got <- result{string(body), err}
+			return
+		}
+	}()
+	select {
+	case <-entered:
+	case r := <-got:
+		t.Fatalf("request: %v", r.err)
+	}
+	if atomic.LoadInt32(&started) != 1 {
+		t.Error("OnStart not called")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	first, second := make(chan error, 1), make(chan error, 1)
+	go func() { first <- app.Shutdown(ctx) }()
+	for { // second Shutdown after the first started
+		app.mu.Lock()
+		closing := app.closing
+		app.mu.Unlock()
+		if closing != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go func() { second <- app.Shutdown(ctx) }()
+
+	select {
+	case <-first:
+		t.Fatal("Shutdown returned with a request in flight")
+	case <-second:
+		t.Fatal("second Shutdown returned with a request in flight")
+	case <-served:
+		t.Fatal("Serve returned with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if atomic.LoadInt32(&stopped) != 0 {
+		t.Error("OnShutdown called before draining")
+	}
+
+	close(release)
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight request: got %q, %v", r.body, r.err)
+	}
+	for name, ch := range map[string]chan error{"first Shutdown": first, "second Shutdown": second, "Serve": served} {
+		select {
+		case err := <-ch:
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		case <-ctx.Done():
+			t.Fatalf("%s did not return", name)
+		}
+	}
+	if n := atomic.LoadInt32(&stopped); n != 1 {
+		t.Errorf("OnShutdown called %d times, want 1", n)
+	}
+}
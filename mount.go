@@ -0,0 +1,179 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Mount 挂载子应用或 net/http handler 到 prefix
+//
+// a *Core sub app keeps its own Options, ViewEngine and error handler:
+// its routes are served under prefix, its handlers render with its views,
+// errors and 404/405 under prefix go to its error handler (the parent's without one).
+// Routes registered on the sub app after Mount are served as well.
+//
+// a http.Handler gets all methods under prefix, the prefix is stripped
+// from the path it sees.
+//
+//  shop := web.New(&web.Options{ViewEngine: web.HTML("./shop/views", ".html")})
+//  shop.Use(new(ShopHandler))
+//  app.Mount("/shop", shop)
+//  app.Mount("/metrics", promhttp.Handler())
+func (c *Core) Mount(prefix string, app interface{}) *Core {
+	prefix = joinPath("", prefix)
+	switch h := app.(type) {
+	case *Core:
+		c.mountApp(prefix, h)
+	case http.Handler:
+		c.mountHandler(prefix, h)
+	default:
+		log.Fatalf("Mount: %T is not a *Core or http.Handler", app)
+	}
+	return c
+}
+
+func (c *Core) mountApp(prefix string, sub *Core) {
+	for p := c; p != nil; p = p.parent {
+		if p == sub {
+			log.Fatalf("Mount: %s mounts an app into itself", prefix)
+		}
+	}
+	if sub.parent != nil {
+		log.Fatalf("Mount: %s app is already mounted at %s", prefix, sub.mountPath())
+	}
+	sub.parent = c
+	sub.mountPrefix = prefix
+	c.mounts = append(c.mounts, sub)
+
+	chain := -1
+	sub.OnRoute(func(r *Route) {
+		// keep the routes of one registration together, Name only sees c's own
+		last := c.last
+		if r.chain != chain {
+			chain = r.chain
+			c.chains++
+		}
+		c.addRoute(c.mountRoute(prefix, r))
+		c.last = last
+	})
+	c.last = nil
+}
+
+// mountRoute copy r of a sub app under prefix, the path follows the options of c.
+func (c *Core) mountRoute(prefix string, r *Route) *Route {
+	original := r.original
+	if original == "" {
+		original = r.Path
+	}
+	if r.isMiddleware && r.isStar {
+		original = "/"
+	}
+	slash := len(original) > 1 && strings.HasSuffix(original, "/")
+	original = joinPath(prefix, original)
+	if slash && c.strictSlash() {
+		original += "/"
+	}
+	p := c.routePath(original)
+	m := &Route{
+		isGet:        r.isGet,
+		isMiddleware: r.isMiddleware,
+		isStar:       !r.isMiddleware && p == "/*",
+		isSlash:      p == "/",
+		isRegex:      r.isRegex,
+		Name:         r.Name,
+		Method:       r.Method,
+		Path:         p,
+		Params:       r.Params,
+		Handler:      r.Handler,
+		original:     original,
+		handler:      r.handler,
+		controller:   r.controller,
+		app:          r.app,
+		src:          r,
+		once:         r.once,
+		segment:      r.segment,
+	}
+	if m.isRegex {
+		regex, err := getRegex(p)
+		if err != nil {
+			log.Fatalf("Router: invalid path pattern: %s", p)
+		}
+		m.Regexp = regex
+	}
+	// middleware prefixes match /shopping for /shop too
+	if m.isMiddleware && !m.isSlash {
+		handler := r.Handler
+		base := strings.TrimRight(p, "/")
+		m.Handler = func(ctx *Ctx) {
+			if ctx.path != base && !strings.HasPrefix(ctx.path, base+"/") {
+				ctx.Next()
+				return
+			}
+			handler(ctx)
+		}
+	}
+	return m
+}
+
+// mountPath full prefix of a mounted app, "" for the root app.
+func (c *Core) mountPath() string {
+	if c.parent == nil {
+		return ""
+	}
+	p := joinPath(c.parent.mountPath(), c.mountPrefix)
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+// owner the mounted app handling path, c if none.
+func (c *Core) owner(path string) *Core {
+	app, best := c, -1
+	for _, m := range c.mounts {
+		p := c.routePath(m.mountPath())
+		if len(p) > best && (path == p || strings.HasPrefix(path, p+"/")) {
+			app, best = m, len(p)
+		}
+	}
+	if app != c {
+		return app.owner(path)
+	}
+	return c
+}
+
+// lookupName find a named route in c or the mounted apps.
+func (c *Core) lookupName(name string) (*Core, *Route) {
+	if r, ok := c.names[name]; ok {
+		return c, r
+	}
+	for _, m := range c.mounts {
+		if app, r := m.lookupName(name); r != nil {
+			return app, r
+		}
+	}
+	for _, h := range c.hosts {
+		if app, r := h.app.lookupName(name); r != nil {
+			return app, r
+		}
+	}
+	return nil, nil
+}
+
+// loadViews load the views of c, the mounted and host apps,
+// host apps sharing the Options of the parent are loaded once.
+func (c *Core) loadViews() {
+	if c.ViewEngine != nil && (c.parent == nil || c.Options != c.parent.Options) {
+		c.regViewFuncs()
+		if err := c.ViewEngine.Load(); err != nil {
+			log.Fatalf("View builder %v", err)
+		}
+	}
+	for _, m := range c.mounts {
+		m.loadViews()
+	}
+	for _, h := range c.hosts {
+		h.app.loadViews()
+	}
+}
@@ -0,0 +1,37 @@
+package web
+
+import "testing"
+
+func TestMountErrorHandler(t *testing.T) {
+	app := New(&Options{ErrorHandler: func(c *Ctx, err error) {
+		c.Response.SetStatusCode(599)
+		c.Send("parent: " + err.Error())
+	}})
+	bare := New()
+	bare.Get("/x", func(c *Ctx) { c.Next(NewError(400, "bad")) })
+	own := New(&Options{ErrorHandler: func(c *Ctx, err error) {
+		c.Response.SetStatusCode(598)
+		c.Send("own: " + err.Error())
+	}})
+	own.Get("/x", func(c *Ctx) { c.Next(NewError(400, "bad")) })
+	app.Mount("/s", bare)
+	app.Mount("/o", own)
+	app.Build()
+
+	cases := []struct {
+		uri  string
+		code int
+		body string
+	}{
+		{"/s/x", 599, "parent: bad"},
+		{"/s/nope", 599, "parent: Not Found"},
+		{"/o/x", 598, "own: bad"},
+		{"/nope", 599, "parent: Not Found"},
+	}
+	for _, tc := range cases {
+		resp := serve(app, MethodGet, tc.uri)
+		if resp.StatusCode() != tc.code || string(resp.Body()) != tc.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", tc.uri, resp.StatusCode(), resp.Body(), tc.code, tc.body)
+		}
+	}
+}
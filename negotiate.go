@@ -0,0 +1,283 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// acceptRange one entry of an Accept* header.
+type acceptRange struct {
+	value  string
+	params string // media type params without q
+	q      float64
+	index  int
+}
+
+// parseAccept parse an Accept* header, entries with an invalid q are dropped.
+func parseAccept(header string) (ranges []acceptRange) {
+	for i, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r := acceptRange{q: 1, index: i}
+		fields := strings.Split(part, ";")
+		r.value = strings.ToLower(strings.TrimSpace(fields[0]))
+		var params []string
+		valid := true
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if len(f) > 2 && (f[0] == 'q' || f[0] == 'Q') && f[1] == '=' {
+				q, err := strconv.ParseFloat(f[2:], 64)
+				if err != nil || q < 0 || q > 1 {
+					valid = false
+				}
+				r.q = q
+				break // accept-ext follow q
+			}
+			if f != "" {
+				params = append(params, strings.ToLower(strings.Replace(f, " ", "", -1)))
+			}
+		}
+		if valid {
+			r.params = strings.Join(params, ";")
+			ranges = append(ranges, r)
+		}
+	}
+	return
+}
+
+// negotiate return the offer the client prefers, "" if none is acceptable.
+// match returns the specificity of a range for an offer, -1 for no match.
+// Ties go to the more specific range, then the header order, then the offer order.
+func negotiate(header string, offers []string, match func(r acceptRange, offer string) int) string {
+	ranges := parseAccept(header)
+	best, bestQ, bestSpec, bestIndex := -1, 0.0, -1, 0
+	for i, offer := range offers {
+		// the most specific matching range sets the quality of an offer
+		q, spec, index := 0.0, -1, 0
+		for _, r := range ranges {
+			if s := match(r, offer); s > spec {
+				q, spec, index = r.q, s, r.index
+			}
+		}
+		if spec < 0 || q == 0 {
+			continue
+		}
+		if best < 0 || q > bestQ || q == bestQ && (spec > bestSpec || spec == bestSpec && index < bestIndex) {
+			best, bestQ, bestSpec, bestIndex = i, q, spec, index
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return offers[best]
+}
+
+// offerMIME expand "json", "html", "text" and other extensions to a media type.
+func offerMIME(offer string) string {
+	if strings.Contains(offer, "/") {
+		return offer
+	}
+	if offer == "text" {
+		return MIMETextPlain
+	}
+	if m, ok := extensionMIME[strings.TrimPrefix(offer, ".")]; ok {
+		return m
+	}
+	return offer
+}
+
+func matchMediaType(r acceptRange, offer string) int {
+	offer = strings.ToLower(offerMIME(offer))
+	var params string
+	if i := strings.IndexByte(offer, ';'); i >= 0 {
+		params = strings.Replace(offer[i+1:], " ", "", -1)
+		offer = strings.TrimSpace(offer[:i])
+	}
+	typ, sub := offer, ""
+	if i := strings.IndexByte(offer, '/'); i >= 0 {
+		typ, sub = offer[:i], offer[i+1:]
+	}
+	rtyp, rsub := r.value, ""
+	if i := strings.IndexByte(r.value, '/'); i >= 0 {
+		rtyp, rsub = r.value[:i], r.value[i+1:]
+	}
+	switch {
+	case r.value == "*/*" || r.value == "*":
+		return 0
+	case rtyp != typ:
+		return -1
+	case rsub == "*":
+		return 1
+	case rsub != sub:
+		return -1
+	case r.params == "":
+		return 2
+	case r.params == params:
+		return 3
+	}
+	return -1
+}
+
+func matchToken(r acceptRange, offer string) int {
+	switch {
+	case r.value == "*":
+		return 0
+	case r.value == strings.ToLower(offer):
+		return 1
+	}
+	return -1
+}
+
+// matchLanguage basic filtering of RFC 4647, "en" matches "en-US".
+func matchLanguage(r acceptRange, offer string) int {
+	offer = strings.ToLower(offer)
+	switch {
+	case r.value == "*":
+		return 0
+	case r.value == offer:
+		return 2
+	case strings.HasPrefix(offer, r.value+"-"):
+		return 1
+	}
+	return -1
+}
+
+// Accepts 根据 Accept 选择响应类型
+// offers are media types or extensions ("json", "html", "text"),
+// returns the offer the client prefers by q-value, "" if none is acceptable.
+// Without Accept header the first offer is returned.
+//
+//  switch c.Accepts("json", "html") {
+//  case "json":
+//  case "html":
+//  }
+func (c *Ctx) Accepts(offers ...string) string {
+	return c.accepts(HeaderAccept, offers, matchMediaType)
+}
+
+// AcceptsEncodings 根据 Accept-Encoding 选择编码
+// identity is acceptable unless the header refuses it.
+func (c *Ctx) AcceptsEncodings(offers ...string) string {
+	header := c.Get(HeaderAcceptEncoding)
+	if header == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	ranges := parseAccept(header)
+	identity := true
+	for _, r := range ranges {
+		if r.value == "identity" || r.value == "*" {
+			identity = r.q > 0
+			if r.value == "identity" {
+				break
+			}
+		}
+	}
+	if identity {
+		// lowest quality, any listed encoding wins
+		header += ",identity;q=0.0001"
+	}
+	return negotiate(header, offers, matchToken)
+}
+
+// AcceptsLanguages 根据 Accept-Language 选择语言, "en" accepts "en-US"
+func (c *Ctx) AcceptsLanguages(offers ...string) string {
+	return c.accepts(HeaderAcceptLanguage, offers, matchLanguage)
+}
+
+// AcceptsCharsets 根据 Accept-Charset 选择字符集
+func (c *Ctx) AcceptsCharsets(offers ...string) string {
+	return c.accepts(HeaderAcceptCharset, offers, matchToken)
+}
+
+func (c *Ctx) accepts(key string, offers []string, match func(acceptRange, string) int) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	header := c.Get(key)
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+	return negotiate(header, offers, match)
+}
+
+// Format 根据 Accept 调用对应的处理函数
+// keys are media types or extensions like Accepts, equal preferences
+// go to the keys in sorted order, "default" runs when nothing matches.
+// The Content-Type is set before the handler runs, Vary gets Accept.
+// Returns a 406 *Error when nothing matches and there is no default.
+//
+//  err := c.Format(map[string]func(){
+//  	"json": func() { c.JSON(user) },
+//  	"html": func() { c.View("user", user) },
+//  })
+func (c *Ctx) Format(handlers map[string]func()) error {
+	offers := make([]string, 0, len(handlers))
+	for k := range handlers {
+		if k != "default" {
+			offers = append(offers, k)
+		}
+	}
+	sort.Strings(offers)
+	c.Response.Header.Add(HeaderVary, HeaderAccept)
+	offer := c.Accepts(offers...)
+	if offer == "" {
+		if fn, ok := handlers["default"]; ok {
+			fn()
+			return nil
+		}
+		return NewError(406)
+	}
+	c.Response.Header.SetContentType(offerMIME(offer))
+	handlers[offer]()
+	return nil
+}
+
+// Negotiate 根据 Accept 以 json, html, xml 或纯文本发送 data
+// html renders view with the ViewEngine and is offered only with a view,
+// codecs of RegisterCodec are offered too, */* gets json.
+// Returns a 406 *Error when nothing matches.
+//
+//  return c.Negotiate(user, "user/show")
+func (c *Ctx) Negotiate(data interface{}, view ...string) error {
+	offers := []string{MIMEApplicationJSON}
+	if len(view) > 0 && view[0] != "" {
+		offers = append(offers, MIMETextHTML)
+	}
+	for _, mime := range c.codecMIMEs() {
+		if mime != MIMEApplicationJSON {
+			offers = append(offers, mime)
+		}
+	}
+	offers = append(offers, MIMETextPlain)
+	c.Response.Header.Add(HeaderVary, HeaderAccept)
+	switch offer := c.Accepts(offers...); offer {
+	case "":
+		return NewError(406)
+	case MIMETextHTML:
+		return c.View(view[0], data)
+	case MIMEApplicationJSON:
+		return c.JSON(data)
+	case MIMETextPlain:
+		c.Response.Header.SetContentType(MIMETextPlainCharsetUTF8)
+		c.Response.SetBodyString(fmt.Sprint(data))
+		return nil
+	default:
+		raw, err := c.codec(offer).Marshal(data)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(offer, "xml") {
+			offer += "; charset=utf-8"
+		}
+		c.Response.Header.SetContentType(offer)
+		c.Response.SetBodyRaw(raw)
+		return nil
+	}
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newCtx Ctx of app for a GET / with header key, value pairs
+func newCtx(app *Core, headers ...string) *Ctx {
+	fctx := new(fasthttp.RequestCtx)
+	newRequest(MethodGet, "/", headers...).CopyTo(&fctx.Request)
+	c := assignCtx(fctx)
+	c.Core, c.app = app, app
+	return c
+}
+
+func TestAccepts(t *testing.T) {
+	app := New()
+	cases := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", []string{"json", "html"}, "json"},
+		{"text/html", []string{"json", "html"}, "html"},
+		{"application/json;q=0.5, text/html;q=0.8", []string{"json", "html"}, "html"},
+		{"application/json;q=0.9, text/html;q=0.8", []string{"html", "json"}, "json"},
+		{"text/*;q=0.5, */*;q=0.1", []string{"json", "text"}, "text"},
+		// q=0 refuses what a wider range accepts
+		{"*/*, application/json;q=0", []string{"json", "html"}, "html"},
+		{"text/html;q=0", []string{"html"}, ""},
+		{"application/json;q=0", []string{"json"}, ""},
+		// equal q: the more specific range, then header order, then offer order
+		{"text/*, text/html", []string{"text/plain", "text/html"}, "text/html"},
+		{"text/plain, text/html", []string{"text/html", "text/plain"}, "text/plain"},
+		{"*/*", []string{"xml", "json"}, "xml"},
+		// media type params
+		{"text/html;level=1, text/html;q=0.5", []string{"text/html;level=1"}, "text/html;level=1"},
+		{"text/html;level=1;q=0.2, text/html;q=0.7", []string{"text/html;level=2", "text/html;level=1"}, "text/html;level=2"},
+		{"text/html; level=1", []string{"text/html;level=2"}, ""},
+		// invalid q drops the entry, case and spaces are ignored
+		{"application/json;q=2, text/html;q=0.1", []string{"json", "html"}, "html"},
+		{"Application/JSON ; Q=0.4, text/html;q=0.3", []string{"html", "json"}, "json"},
+		{"image/png", []string{"json", "html"}, ""},
+	}
+	for _, tc := range cases {
+		if got := newCtx(app, HeaderAccept, tc.accept).Accepts(tc.offers...); got != tc.want {
+			t.Errorf("Accept %q %v: got %q, want %q", tc.accept, tc.offers, got, tc.want)
+		}
+	}
+}
+
+func TestAcceptsEncodingsLanguages(t *testing.T) {
+	app := New()
+	encodings := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"", []string{"gzip", "identity"}, "gzip"},
+		{"gzip;q=0.5, br", []string{"gzip", "br"}, "br"},
+		{"gzip", []string{"br", "identity"}, "identity"}, // identity is acceptable unless refused
+		{"gzip, identity;q=0", []string{"br", "identity"}, ""},
+		{"*;q=0", []string{"identity"}, ""},
+		{"*;q=0, identity", []string{"gzip", "identity"}, "identity"},
+		{"br;q=0, *", []string{"br", "gzip"}, "gzip"},
+		{"gzip;q=0", []string{"gzip"}, ""},
+	}
+	for _, tc := range encodings {
+		if got := newCtx(app, HeaderAcceptEncoding, tc.header).AcceptsEncodings(tc.offers...); got != tc.want {
+			t.Errorf("Accept-Encoding %q %v: got %q, want %q", tc.header, tc.offers, got, tc.want)
+		}
+	}
+
+	languages := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"en", []string{"fr", "en-US"}, "en-US"},
+		{"en-US, en;q=0.8, fr;q=0.9", []string{"en-GB", "fr", "en-US"}, "en-US"},
+		{"en-US;q=0.5, fr;q=0.9", []string{"en-US", "fr"}, "fr"},
+		{"zh-CN, *;q=0.1", []string{"de", "zh-cn"}, "zh-cn"},
+		{"de", []string{"en"}, ""},
+	}
+	for _, tc := range languages {
+		if got := newCtx(app, HeaderAcceptLanguage, tc.header).AcceptsLanguages(tc.offers...); got != tc.want {
+			t.Errorf("Accept-Language %q %v: got %q, want %q", tc.header, tc.offers, got, tc.want)
+		}
+	}
+	if got := newCtx(app, HeaderAcceptCharset, "iso-8859-1;q=0.5, utf-8").AcceptsCharsets("iso-8859-1", "utf-8"); got != "utf-8" {
+		t.Errorf("Accept-Charset: got %q", got)
+	}
+}
+
+type negotiateItem struct {
+	XMLName xml.Name `json:"-" xml:"item"`
+	N       int      `json:"n" xml:"n"`
+}
+
+func TestFormatNegotiate(t *testing.T) {
+	item := negotiateItem{N: 1}
+	app := New()
+	app.Get("/format", func(c *Ctx) {
+		c.Next(c.Format(map[string]func(){
+			"json": func() { c.Send("json") },
+			"html": func() { c.Send("html") },
+		}))
+	})
+	app.Get("/default", func(c *Ctx) {
+		c.Next(c.Format(map[string]func(){
+			"json":    func() { c.Send("json") },
+			"default": func() { c.Send("default") },
+		}))
+	})
+	app.Get("/negotiate", func(c *Ctx) {
+		c.Next(c.Negotiate(item))
+	})
+	app.Build()
+
+	cases := []struct {
+		uri, accept string
+		code        int
+		ctype, body string
+	}{
+		{"/format", "text/html", 200, MIMETextHTML, "html"},
+		{"/format", "*/*", 200, MIMETextHTML, "html"}, // sorted keys
+		{"/format", "application/json, text/html;q=0.9", 200, MIMEApplicationJSON, "json"},
+		{"/format", "image/png", 406, "", ""},
+		{"/default", "image/png", 200, "", "default"},
+		{"/negotiate", "application/json", 200, MIMEApplicationJSON, `{"n":1}`},
+		{"/negotiate", "application/xml", 200, "application/xml; charset=utf-8", "<item><n>1</n></item>"},
+		{"/negotiate", "text/plain", 200, MIMETextPlainCharsetUTF8, fmt.Sprint(item)},
+		{"/negotiate", "*/*", 200, MIMEApplicationJSON, `{"n":1}`},
+		{"/negotiate", "text/html", 406, "", ""}, // html needs a view
+		{"/negotiate", "image/png", 406, "", ""},
+	}
+	for _, tc := range cases {
+		resp := serveRequest(app, newRequest(MethodGet, tc.uri, HeaderAccept, tc.accept))
+		ctype, body := string(resp.Header.ContentType()), string(resp.Body())
+		switch {
+		case resp.StatusCode() != tc.code:
+			t.Errorf("%s %q: got %d, want %d", tc.uri, tc.accept, resp.StatusCode(), tc.code)
+		case tc.code != 200:
+		case tc.ctype != "" && ctype != tc.ctype, body != tc.body:
+			t.Errorf("%s %q: got %s %q, want %s %q", tc.uri, tc.accept, ctype, body, tc.ctype, tc.body)
+		}
+		if vary := string(resp.Header.Peek(HeaderVary)); vary != HeaderAccept && tc.code == 200 {
+			t.Errorf("%s %q: Vary %q", tc.uri, tc.accept, vary)
+		}
+	}
+}
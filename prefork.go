@@ -0,0 +1,297 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Sharding: https://www.nginx.com/blog/socket-sharding-nginx-release-1-9-1/
+//
+// The parent process only supervises: it owns the listener and passes
+// it to the children as fd 3, fd 4 is a pipe the child closes once it
+// serves. Crashed children restart with backoff, SIGHUP replaces them
+// one at a time by a fresh exec of the binary, SIGINT/SIGTERM are
+// forwarded and the parent exits after all children.
+// Linux only, prefork is disabled on windows.
+
+const (
+	envWorkerIndex = "WEB_WORKER_INDEX"
+
+	preforkReadyTimeout = 10 * time.Second
+	preforkMinBackoff   = 100 * time.Millisecond
+	preforkMaxBackoff   = 10 * time.Second
+	preforkStableAfter  = 5 * time.Second // a child living longer resets backoff
+)
+
+// WorkerIndex returns the index of this prefork child, -1 if not a child.
+func WorkerIndex() int {
+	if !isChild() {
+		return -1
+	}
+	i, err := strconv.Atoi(os.Getenv(envWorkerIndex))
+	if err != nil {
+		return -1
+	}
+	return i
+}
+
+// notifyReady tells the supervisor this child serves.
+func notifyReady() {
+	f := os.NewFile(4, "ready")
+	if f == nil {
+		return
+	}
+	f.Write([]byte{1})
+	f.Close()
+}
+
+type worker struct {
+	index   int
+	cmd     *exec.Cmd
+	started time.Time
+	retired bool          // replaced by reload or stopped, do not restart
+	done    chan struct{} // closed when the process exited
+}
+
+type supervisor struct {
+	mu       sync.Mutex
+	file     *os.File
+	workers  []*worker
+	slots    []slot
+	live     map[*worker]struct{} // started and not exited yet, stop signals them all
+	stopping bool
+	wg       sync.WaitGroup
+}
+
+// slot of a child, spawns of a slot are serialized (the caller holds it),
+// monitor and reload race otherwise. backoff and crashed are guarded by mu.
+type slot struct {
+	sync.Mutex
+	backoff time.Duration // restart delay, doubled by each quick crash
+	crashed time.Time     // last crash or failed restart
+}
+
+func (c *Core) prefork(addr string) (ln net.Listener, err error) {
+	if isChild() {
+		runtime.GOMAXPROCS(1)
+		return net.FileListener(os.NewFile(3, ""))
+	}
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return ln, err
+	}
+	tcplistener, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return ln, err
+	}
+	fl, err := tcplistener.File()
+	if err != nil {
+		return ln, err
+	}
+
+	n := c.PreforkChildren
+	if n <= 0 {
+		n = runtime.NumCPU() / 2
+	}
+	s := &supervisor{
+		file:    fl,
+		workers: make([]*worker, n),
+		slots:   make([]slot, n),
+		live:    make(map[*worker]struct{}),
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for i := 0; i < n; i++ {
+		s.slots[i].Lock()
+		_, err := s.spawn(i)
+		s.slots[i].Unlock()
+		if err != nil {
+			s.stop(syscall.SIGTERM)
+			return ln, err
+		}
+	}
+	fmt.Printf("Prefork %d children on %s, pid %d\n", n, Cyan(tcplistener.Addr().String()), os.Getpid())
+
+	for sig := range sigs {
+		if sig == syscall.SIGHUP {
+			s.reload()
+			continue
+		}
+		s.stop(sig)
+		break
+	}
+	os.Exit(0)
+	return
+}
+
+// spawn start a child for slot i and wait until it serves,
+// the caller holds the lock of the slot.
+func (s *supervisor) spawn(i int) (*worker, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(os.Args[0], append(os.Args[1:], "-prefork", "-child")...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{s.file, w}
+	cmd.Env = append(os.Environ(), envWorkerIndex+"="+strconv.Itoa(i))
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	wk := &worker{index: i, cmd: cmd, started: time.Now(), done: make(chan struct{})}
+	s.mu.Lock()
+	s.live[wk] = struct{}{}
+	s.wg.Add(1)
+	stopping := s.stopping
+	s.mu.Unlock()
+	go s.monitor(wk)
+	if stopping { // stop already signaled the others
+		s.retire(wk, syscall.SIGTERM)
+		return nil, fmt.Errorf("prefork: child %d started while stopping", i)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := r.Read(b)
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+		if err != nil { // exited before serving
+			err = fmt.Errorf("prefork: child %d failed to start", i)
+		}
+	case <-time.After(preforkReadyTimeout):
+		err = fmt.Errorf("prefork: child %d not ready after %v", i, preforkReadyTimeout)
+	}
+	if err != nil {
+		s.retire(wk, syscall.SIGKILL)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.workers[i] = wk
+	s.mu.Unlock()
+	return wk, nil
+}
+
+// monitor wait the child and restart it if it crashed.
+func (s *supervisor) monitor(wk *worker) {
+	defer s.wg.Done()
+	err := wk.cmd.Wait()
+	s.mu.Lock()
+	delete(s.live, wk)
+	s.mu.Unlock()
+	close(wk.done)
+
+	started := wk.started
+	for {
+		s.mu.Lock()
+		restart := !s.stopping && !wk.retired && s.workers[wk.index] == wk
+		s.mu.Unlock()
+		if !restart {
+			return
+		}
+		at := s.crash(wk.index, started)
+		log.Printf("prefork: child %d (pid %d) exited: %v, restart in %v\n", wk.index, wk.cmd.Process.Pid, err, time.Until(at).Round(time.Millisecond))
+		time.Sleep(time.Until(at))
+		slot := &s.slots[wk.index]
+		slot.Lock()
+		// reload may have replaced the child meanwhile
+		s.mu.Lock()
+		restart = !s.stopping && !wk.retired && s.workers[wk.index] == wk
+		s.mu.Unlock()
+		if !restart {
+			slot.Unlock()
+			return
+		}
+		started = time.Now()
+		_, err = s.spawn(wk.index)
+		slot.Unlock()
+		if err == nil {
+			return
+		}
+	}
+}
+
+// crash record a crash of the child of slot i started at started and return
+// when to restart it: the backoff doubles for each child that crashes
+// within preforkStableAfter and is reset by a child living longer.
+func (s *supervisor) crash(i int, started time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sl := &s.slots[i]
+	now := time.Now()
+	switch {
+	case sl.backoff == 0, now.Sub(started) > preforkStableAfter:
+		sl.backoff = preforkMinBackoff
+	default:
+		if sl.backoff *= 2; sl.backoff > preforkMaxBackoff {
+			sl.backoff = preforkMaxBackoff
+		}
+	}
+	sl.crashed = now
+	return sl.crashed.Add(sl.backoff)
+}
+
+// reload replace children one by one, the new one serves before the old one stops.
+func (s *supervisor) reload() {
+	log.Printf("prefork: reloading %d children\n", len(s.workers))
+	for i := range s.workers {
+		s.slots[i].Lock()
+		s.mu.Lock()
+		old := s.workers[i]
+		s.mu.Unlock()
+		_, err := s.spawn(i)
+		s.slots[i].Unlock()
+		if err != nil {
+			log.Printf("prefork: reload child %d: %v, keep the old one\n", i, err)
+			continue
+		}
+		if old != nil {
+			s.retire(old, syscall.SIGTERM)
+		}
+	}
+}
+
+// retire stop a child without restarting it.
+func (s *supervisor) retire(wk *worker, sig os.Signal) {
+	s.mu.Lock()
+	wk.retired = true
+	s.mu.Unlock()
+	wk.cmd.Process.Signal(sig)
+	<-wk.done
+}
+
+// stop forward sig to every running child, also those still starting, and wait for them.
+func (s *supervisor) stop(sig os.Signal) {
+	s.mu.Lock()
+	s.stopping = true
+	workers := make([]*worker, 0, len(s.live))
+	for wk := range s.live {
+		workers = append(workers, wk)
+	}
+	s.mu.Unlock()
+	for _, wk := range workers {
+		wk.cmd.Process.Signal(sig)
+	}
+	s.wg.Wait()
+}
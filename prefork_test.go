@@ -0,0 +1,33 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreforkBackoff(t *testing.T) {
+	s := &supervisor{slots: make([]slot, 2)}
+	quick := func(i int) time.Duration {
+		s.crash(i, time.Now())
+		return s.slots[i].backoff
+	}
+	want := preforkMinBackoff
+	for n := 0; n < 10; n++ {
+		if got := quick(0); got != want {
+			t.Fatalf("crash %d: backoff %v, want %v", n, got, want)
+		}
+		if want *= 2; want > preforkMaxBackoff {
+			want = preforkMaxBackoff
+		}
+	}
+	if got := s.slots[1].backoff; got != 0 {
+		t.Errorf("other slot backoff %v", got)
+	}
+	at := s.crash(0, time.Now().Add(-2*preforkStableAfter))
+	if got := s.slots[0].backoff; got != preforkMinBackoff {
+		t.Errorf("stable child: backoff %v, want %v", got, preforkMinBackoff)
+	}
+	if !at.Equal(s.slots[0].crashed.Add(preforkMinBackoff)) {
+		t.Errorf("restart at %v, crashed %v", at, s.slots[0].crashed)
+	}
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// PanicError a recovered panic and the stack where it happened.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover 捕获 panic 的中间件, 需要最先注册
+// panics become a 500 through the error handler and the stack is logged,
+// with Options.Debug a developer page shows stack, route, params, headers and user values.
+//
+//  app.Use(web.Recover())
+func Recover() func(*Ctx) {
+	return func(c *Ctx) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			err := &PanicError{Value: r, Stack: debug.Stack()}
+			log.Printf("%s %s %v\n%s", c.method, c.path, err, err.Stack)
+			c.Response.ResetBody()
+			if c.Core != nil && c.Debug {
+				c.debugPage(err)
+				return
+			}
+			c.handleError(c, err)
+		}()
+		c.Next()
+	}
+}
+
+// debugPage render the developer error page of a panic.
+func (c *Ctx) debugPage(err *PanicError) {
+	route := c.Router()
+	params := make(map[string]string)
+	for i := range route.Params {
+		params[route.Params[i]] = c.Params(route.Params[i])
+	}
+	headers := make(map[string]string)
+	c.Request.Header.VisitAll(func(k, v []byte) {
+		headers[string(k)] = string(v)
+	})
+	vars := make(map[string]string)
+	c.VisitUserValues(func(k []byte, v interface{}) {
+		vars[string(k)] = fmt.Sprintf("%#v", v)
+	})
+
+	c.Response.SetStatusCode(500)
+	if c.errorFormat() == MIMEApplicationJSON {
+		c.JSON(map[string]interface{}{
+			"code":    500,
+			"message": err.Error(),
+			"stack":   strings.Split(string(err.Stack), "\n"),
+			"route":   route.Method + " " + route.Path,
+			"params":  params,
+			"headers": headers,
+			"vars":    vars,
+		})
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>500 Internal Server Error</title>`)
+	b.WriteString(`<style>body{font-family:sans-serif;margin:2em}pre{background:#f6f6f6;padding:1em;overflow:auto}` +
+		`td{padding:2px 8px;vertical-align:top;font-family:monospace}</style></head><body>`)
+	fmt.Fprintf(&b, "<h1>%s</h1>", html.EscapeString(err.Error()))
+	fmt.Fprintf(&b, "<p>%s %s</p>", html.EscapeString(c.method), html.EscapeString(string(c.RequestURI())))
+	fmt.Fprintf(&b, "<h2>Stack</h2><pre>%s</pre>", html.EscapeString(string(err.Stack)))
+	fmt.Fprintf(&b, "<h2>Route</h2><table><tr><td>%s</td><td>%s</td></tr></table>",
+		html.EscapeString(route.Method), html.EscapeString(route.Path))
+	debugTable(&b, "Params", params)
+	debugTable(&b, "Headers", headers)
+	debugTable(&b, "Vars", vars)
+	b.WriteString("</body></html>")
+
+	c.Response.Header.SetContentType(MIMETextHTMLCharsetUTF8)
+	c.Response.SetBodyString(b.String())
+}
+
+func debugTable(b *strings.Builder, title string, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(b, "<h2>%s</h2><table>", title)
+	for _, k := range keys {
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%s</td></tr>", html.EscapeString(k), html.EscapeString(m[k]))
+	}
+	b.WriteString("</table>")
+}
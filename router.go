@@ -1,8 +1,13 @@
 package web
 
 import (
+	"fmt"
+	"log"
+	"net/url"
 	"regexp"
 	"strings"
+
+	"github.com/aymerick/raymond"
 )
 
 // Route 路由
@@ -15,6 +20,7 @@ type Route struct {
 	isSlash bool // path == "/"
 	isRegex bool // needs regex parsing
 
+	Name   string         // route name, see Core.Name
 	Method string         // http method
 	Path   string         // original path
 	Params []string       // path params
@@ -23,40 +29,164 @@ type Route struct {
 	Handler  func(*Ctx) // ctx handler
 	Handlers []Handler  `json:"-"` // Ctx handlers
 
+	pos        int    // position in Core.routes
+	original   string // path with original case, used by the tree
+	chain      int    // registration the route belongs to, see Core.Routes
+	handler    string // handler func name
+	controller string // controller registering the route
+	app        *Core  // app registering the route, differs for mounted apps
+	src        *Route // route of the mounted app this one is copied from
+	once       uint64 // handler shared by several chains runs once per request, see Group
+	segment    bool   // middleware matching whole path segments only: /admin not /administrator
+}
+
+// Name 命名上一次注册的路由, 用于 URL 反向生成
+//
+//  app.Get("/user/:id", h).Name("user")
+//  app.URL("user", 1) // /user/1
+func (c *Core) Name(name string) *Core {
+	if len(c.last) == 0 {
+		log.Fatalf("Router: Name(%q) without a route", name)
+	}
+	route := c.last[len(c.last)-1]
+	if r, ok := c.names[name]; ok && r != route {
+		log.Fatalf("Router: duplicate route name %q: %s %s", name, r.Method, r.Path)
+	}
+	for _, r := range c.last {
+		r.Name = name
+	}
+	c.names[name] = route
+	return c
 }
 
-func (r *Route) matchRoute(method, path string) (match bool, values []string) {
-	if r.isMiddleware {
-		if r.isStar || r.isSlash {
-			return true, values
+// autoName names controller routes, the first controller keeps a name.
+func (c *Core) autoName(name string) {
+	if _, ok := c.names[name]; ok || len(c.last) == 0 {
+		return
+	}
+	c.Name(name)
+}
+
+// URL 通过路由名称生成 path
+// params fill :param, :param? and * in order, or one map[string]interface{}
+// or map[string]string fills them by name, absent optional params are dropped.
+// Names of mounted apps are found too, their paths have the mount prefix.
+func (c *Core) URL(name string, params ...interface{}) (string, error) {
+	app, route := c.lookupName(name)
+	if route == nil {
+		return "", fmt.Errorf("url: route %q not found", name)
+	}
+	var named map[string]interface{}
+	if len(params) == 1 {
+		switch m := params[0].(type) {
+		case map[string]interface{}:
+			named = m
+		case map[string]string:
+			named = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				named[k] = v
+			}
+		}
+	}
+
+	original := route.original
+	if original == "" {
+		original = route.Path
+	}
+	var b strings.Builder
+	b.WriteString(app.mountPath())
+	used := 0
+	for _, seg := range splitPath(original) {
+		if seg == "" {
+			continue
 		}
-		if strings.HasPrefix(path, r.Path) {
-			return true, values
+		wild := seg[0] != ':' && strings.Contains(seg, "*")
+		if seg[0] != ':' && !wild {
+			b.WriteString("/" + seg)
+			continue
 		}
-		// middlewares dont support regex so bye
-		return false, values
+		key, cons, optional := "*", "", false
+		if !wild {
+			key, cons, optional = paramSegment(seg)
+		}
+		var v interface{}
+		if named != nil {
+			v = named[key]
+		} else if used < len(params) {
+			v = params[used]
+		}
+		used++
+		value := ""
+		if v != nil {
+			value = fmt.Sprint(v)
+		}
+		switch {
+		case wild:
+			parts := strings.Split(value, "/")
+			for i := range parts {
+				parts[i] = url.PathEscape(parts[i])
+			}
+			value = strings.Replace(seg, "*", strings.Join(parts, "/"), 1)
+			if value == "" {
+				continue
+			}
+		case value == "":
+			if optional {
+				continue
+			}
+			return "", fmt.Errorf("url: route %q missing param %q", name, key)
+		case cons != "" && !parseConstraint(cons).match(value):
+			return "", fmt.Errorf("url: route %q param %q does not match %s", name, key, cons)
+		default:
+			value = url.PathEscape(value)
+		}
+		b.WriteString("/" + value)
 	}
-	if r.Method == method || r.Method[0] == '*' || (r.isGet && len(method) == 4 && method == "HEAD") {
-		if r.isStar { // '*' means we match anything
-			return true, values
+	if named == nil && len(params) > used {
+		return "", fmt.Errorf("url: route %q takes %d params, got %d", name, used, len(params))
+	}
+	if len(original) > 1 && strings.HasSuffix(original, "/") { // StrictRouting
+		b.WriteString("/")
+	}
+	if b.Len() == 0 {
+		return "/", nil
+	}
+	return b.String(), nil
+}
+
+// regViewFuncs register url and urlpath helpers unless the user set them.
+//
+//  html:       {{ url "Handler.GetUser" .ID }}
+//  handlebars: {{ url "Handler.GetUser" id=user.ID }}
+func (c *Core) regViewFuncs() {
+	switch v := c.ViewEngine.(type) {
+	case *HTMLEngine:
+		fn := func(name string, params ...interface{}) (string, error) {
+			return c.URL(name, params...)
 		}
-		if r.isSlash && path == "/" { // simple '/' bool
-			return true, values
+		for _, k := range []string{"url", "urlpath"} {
+			v.rmu.RLock()
+			_, ok := v.funcs[k]
+			v.rmu.RUnlock()
+			if !ok {
+				v.AddFunc(k, fn)
+			}
 		}
-		if r.isRegex && r.Regexp.MatchString(path) {
-			if len(r.Params) > 0 {
-				matches := r.Regexp.FindAllStringSubmatch(path, -1)
-				if len(matches) > 0 && len(matches[0]) > 1 {
-					values = matches[0][1:len(matches[0])]
-					return true, values
-				}
-				return false, values
+	case *HandlebarsEngine:
+		fn := func(name string, options *raymond.Options) string {
+			u, err := c.URL(name, options.Hash())
+			if err != nil {
+				log.Printf("views: %v\n", err)
 			}
-			return true, values
+			return u
 		}
-		if len(r.Path) == len(path) && r.Path == path {
-			return true, values
+		for _, k := range []string{"url", "urlpath"} {
+			v.rmu.RLock()
+			_, ok := v.helpers[k]
+			v.rmu.RUnlock()
+			if !ok {
+				v.AddFunc(k, fn)
+			}
 		}
 	}
-	return false, values
 }
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	h := func(c *Ctx) {}
+	app := New()
+	app.Get("/user/:id", h).Name("user")
+	app.Get("/post/:slug?", h).Name("post")
+	app.Get("/files/*", h).Name("files")
+	app.Get("/n/:id<int>", h).Name("num")
+	app.Get("/u/:uid/orders/:oid", h).Name("order")
+	shop := New()
+	shop.Get("/item/:id", h).Name("item")
+	app.Mount("/shop", shop)
+	strict := New(&Options{StrictRouting: true})
+	strict.Get("/dir/", h).Name("dir")
+	strict.Get("/dir/:id/", h).Name("sub")
+
+	cases := []struct {
+		app    *Core
+		name   string
+		params []interface{}
+		want   string
+		err    string
+	}{
+		{app, "user", []interface{}{7}, "/user/7", ""},
+		{app, "user", []interface{}{"a b/c"}, "/user/a%20b%2Fc", ""},
+		{app, "user", nil, "", `route "user" missing param "id"`},
+		{app, "user", []interface{}{1, 2}, "", `route "user" takes 1 params, got 2`},
+		{app, "post", nil, "/post", ""},
+		{app, "post", []interface{}{"hi"}, "/post/hi", ""},
+		{app, "files", []interface{}{"a/b c.txt"}, "/files/a/b%20c.txt", ""},
+		{app, "files", nil, "/files", ""},
+		{app, "num", []interface{}{5}, "/n/5", ""},
+		{app, "num", []interface{}{"x"}, "", `route "num" param "id" does not match int`},
+		{app, "order", []interface{}{map[string]interface{}{"uid": 1, "oid": "o 2"}}, "/u/1/orders/o%202", ""},
+		{app, "order", []interface{}{map[string]string{"oid": "2", "uid": "1"}}, "/u/1/orders/2", ""},
+		{app, "order", []interface{}{map[string]string{"uid": "1"}}, "", `missing param "oid"`},
+		{app, "item", []interface{}{3}, "/shop/item/3", ""},
+		{shop, "item", []interface{}{3}, "/shop/item/3", ""},
+		{app, "nope", nil, "", `route "nope" not found`},
+		{strict, "dir", nil, "/dir/", ""},
+		{strict, "sub", []interface{}{1}, "/dir/1/", ""},
+	}
+	for _, tc := range cases {
+		got, err := tc.app.URL(tc.name, tc.params...)
+		if got != tc.want || tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("URL(%q, %v): got %q %v, want %q %q", tc.name, tc.params, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+// handlebarsUsed raymond helpers are global, Handlebars panics the second time in a process
+var handlebarsUsed bool
+
+func TestURLViewHelpers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web-url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"html/page.html": `{{ url "order" .UID .OID }} {{ urlpath "post" }}`,
+		"hbs/page.hbs":   `{{url "order" uid=1 oid=2}} {{urlpath "item" id=3}}`,
+	}
+	for name, body := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engines := map[string]ViewEngine{
+		"html": HTML(filepath.Join(dir, "html"), ".html"),
+	}
+	if !handlebarsUsed {
+		handlebarsUsed = true
+		engines["handlebars"] = Handlebars(filepath.Join(dir, "hbs"), ".hbs")
+	}
+	wants := map[string]string{
+		"html":       "/u/1/orders/2 /post",
+		"handlebars": "/u/1/orders/2 /shop/item/3",
+	}
+	views := map[string]string{"html": "page.html", "handlebars": "page"}
+	for name, engine := range engines {
+		name, view := name, views[name]
+		app := New(&Options{ViewEngine: engine})
+		app.Get("/u/:uid/orders/:oid", func(c *Ctx) {}).Name("order")
+		app.Get("/post/:slug?", func(c *Ctx) {}).Name("post")
+		shop := New()
+		shop.Get("/item/:id", func(c *Ctx) {}).Name("item")
+		app.Mount("/shop", shop)
+		app.Get("/", func(c *Ctx) {
+			if err := c.View(view, map[string]interface{}{"UID": 1, "OID": 2}); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		})
+		app.Build()
+		if body := string(serve(app, MethodGet, "/").Body()); body != wants[name] {
+			t.Errorf("%s: got %q, want %q", name, body, wants[name])
+		}
+	}
+}
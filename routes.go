@@ -0,0 +1,136 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+// RoutePrinter 启动时打印路由表的格式
+type RoutePrinter int
+
+// Route printers, Options.PrintRoutes
+const (
+	PrintColored RoutePrinter = iota // default
+	PrintPlain
+	PrintJSON
+	PrintNone
+)
+
+// RouteInfo 路由信息
+type RouteInfo struct {
+	Method     string   `json:"method"`         // USE for middleware, ALL for every method
+	Host       string   `json:"host,omitempty"` // pattern of Core.Host
+	Path       string   `json:"path"`
+	Name       string   `json:"name,omitempty"`
+	Params     []string `json:"params,omitempty"`
+	Handler    string   `json:"handler"`
+	Middleware []string `json:"middleware,omitempty"` // handlers running before Handler
+	Controller string   `json:"controller,omitempty"`
+}
+
+// Routes 返回已注册的路由表, in registration order,
+// handlers registered together are one RouteInfo, routes of hosts follow.
+func (c *Core) Routes() []RouteInfo {
+	var infos []RouteInfo
+	for i := 0; i < len(c.routes); {
+		j := i
+		for j+1 < len(c.routes) && c.routes[j+1].chain == c.routes[i].chain {
+			j++
+		}
+		r := c.routes[j]
+		info := RouteInfo{
+			Method:     r.Method,
+			Path:       r.original,
+			Name:       r.Name,
+			Params:     r.Params,
+			Handler:    r.handler,
+			Controller: r.controller,
+		}
+		switch {
+		case r.isMiddleware:
+			info.Method = "USE"
+		case r.Method == "*":
+			info.Method = "ALL"
+		}
+		for s := r.src; info.Name == "" && s != nil; s = s.src {
+			info.Name = s.Name
+		}
+		if info.Path == "" {
+			info.Path = r.Path
+		}
+		for _, m := range c.routes[i:j] {
+			info.Middleware = append(info.Middleware, m.handler)
+		}
+		infos = append(infos, info)
+		i = j + 1
+	}
+	for _, h := range c.hosts {
+		for _, info := range h.app.Routes() {
+			if info.Host == "" {
+				info.Host = h.pattern
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// printRoutes print the route table as Options.PrintRoutes says.
+func (c *Core) printRoutes() {
+	switch c.PrintRoutes {
+	case PrintNone:
+		return
+	case PrintJSON:
+		raw, err := json.MarshalIndent(c.Routes(), "", "  ")
+		if err != nil {
+			return
+		}
+		fmt.Println(string(raw))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, r := range c.Routes() {
+		method := r.Method
+		if c.PrintRoutes == PrintColored {
+			method = Magenta(method)
+		}
+		fmt.Fprintf(w, "| %s\t%s\t%s\t%s\n", method, r.Host+r.Path, r.Name, r.Handler)
+	}
+	w.Flush()
+}
+
+// nameHandlers set the handler names of the last registration from args,
+// wrapped handlers would show the wrapper otherwise.
+func (c *Core) nameHandlers(args []interface{}) {
+	i := len(c.last) - 1
+	for a := len(args) - 1; a >= 0 && i >= 0; a-- {
+		switch args[a].(type) {
+		case string, handle:
+			continue
+		}
+		c.last[i].handler = funcName(args[a])
+		i--
+	}
+}
+
+// funcName short name of a func: "web.(*Handler).Preload".
+func funcName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", fn)
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return ""
+	}
+	name := strings.TrimSuffix(f.Name(), "-fm")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
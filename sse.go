@@ -0,0 +1,283 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamClosed Send on a closed or disconnected EventStream
+var ErrStreamClosed = errors.New("sse: stream closed")
+
+// Event server-sent event
+type Event struct {
+	ID    string
+	Event string        // event type, "" is message
+	Data  interface{}   // string and []byte are sent as is, others encoded by the json codec
+	Retry time.Duration // client reconnect delay
+}
+
+// SSEConfig options of Ctx.SSE
+type SSEConfig struct {
+	// Heartbeat 心跳间隔 default 15s, -1 disables,
+	// comments keep proxies from closing the stream and detect disconnected clients.
+	Heartbeat time.Duration
+	// Retry sent to the client when the stream opens
+	Retry time.Duration
+	// Replay events since Last-Event-ID are sent first on reconnect,
+	// add the events to it where they are produced, Broker does it for its topics.
+	Replay ReplayBuffer
+}
+
+// EventStream stream of Ctx.SSE, safe for concurrent use
+type EventStream struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	codec  Codec
+	lastID string
+	done   chan struct{}
+	once   sync.Once
+}
+
+// SSE 发送 server-sent events (text/event-stream)
+// fn starts in its own goroutine like Stream, so it must not use c,
+// the stream ends when fn returns or panics (the panic is logged, Recover
+// does not see it). Disconnected clients are detected on the
+// next write or heartbeat, then Done is closed and Send returns ErrStreamClosed.
+// Options.WriteTimeout also limits the stream, leave it zero for long streams.
+//
+//  app.Get("/events", func(c *web.Ctx) {
+//  	ch := notices.Subscribe(c.Params("user"))
+//  	c.SSE(func(s *web.EventStream) {
+//  		defer notices.Unsubscribe(ch)
+//  		for {
+//  			select {
+//  			case n := <-ch:
+//  				s.Send(web.Event{ID: n.ID, Event: "notice", Data: n})
+//  			case <-s.Done():
+//  				return
+//  			}
+//  		}
+//  	})
+//  })
+func (c *Ctx) SSE(fn func(stream *EventStream), config ...SSEConfig) {
+	var cfg SSEConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Heartbeat == 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	s := &EventStream{
+		codec:  c.jsonCodec(),
+		lastID: c.Get(HeaderLastEventID),
+		done:   make(chan struct{}),
+	}
+	c.Response.Header.SetContentType(MIMETextEventStream)
+	c.Set(HeaderCacheControl, "no-cache")
+	c.Set("X-Accel-Buffering", "no") // nginx
+	c.streamWriter("SSE", func(w *bufio.Writer) {
+		s.w = w
+		defer s.Close()
+		// send the headers right away
+		if cfg.Retry > 0 {
+			s.Send(Event{Retry: cfg.Retry})
+		} else {
+			s.Comment("")
+		}
+		if cfg.Replay != nil && s.lastID != "" {
+			for _, e := range cfg.Replay.Since(s.lastID) {
+				s.Send(e)
+			}
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		if cfg.Heartbeat > 0 {
+			go s.heartbeat(cfg.Heartbeat, stop)
+		}
+		fn(s)
+	})
+}
+
+func (s *EventStream) heartbeat(d time.Duration, stop chan struct{}) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if s.Comment("") != nil {
+				return
+			}
+		case <-stop:
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Send 发送事件, multi-line data is sent as several data lines
+func (s *EventStream) Send(e Event) error {
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		raw, err := s.codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(raw)
+	}
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + oneLine(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + oneLine(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(int64(e.Retry/time.Millisecond), 10) + "\n")
+	}
+	if e.Data != nil {
+		for _, line := range lines(data) {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Comment 发送注释, ignored by clients
+func (s *EventStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range lines(text) {
+		b.WriteString(":" + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+func (s *EventStream) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return ErrStreamClosed
+	default:
+	}
+	_, err := s.w.WriteString(msg)
+	if err == nil {
+		err = s.w.Flush()
+	}
+	if err != nil {
+		s.close()
+		return ErrStreamClosed
+	}
+	return nil
+}
+
+// LastEventID Last-Event-ID of a reconnecting client
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Done closed when the stream is closed or the client went away
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 结束 stream, fn of Ctx.SSE should return soon after
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	s.close()
+	s.mu.Unlock()
+}
+
+func (s *EventStream) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// lines split s at \r\n, \r and \n
+func lines(s string) []string {
+	return strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s), "\n")
+}
+
+// oneLine drop line breaks of id and event fields
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// ReplayBuffer 已发送的事件, 用于 Last-Event-ID 断线续传
+type ReplayBuffer interface {
+	// Add store an event of topic ("" without topics), events without ID are not stored.
+	Add(topic string, e Event)
+	// Since events after the event id of the topics (all without topics) in order,
+	// nil if id is not in the buffer any more.
+	Since(id string, topics ...string) []Event
+}
+
+// NewReplayBuffer 内存 ReplayBuffer, keeps the last size events
+func NewReplayBuffer(size int) ReplayBuffer {
+	if size <= 0 {
+		size = 100
+	}
+	return &memoryReplay{size: size}
+}
+
+type replayEvent struct {
+	topic string
+	Event
+}
+
+type memoryReplay struct {
+	mu     sync.RWMutex
+	size   int
+	events []replayEvent
+}
+
+func (m *memoryReplay) Add(topic string, e Event) {
+	if e.ID == "" {
+		return
+	}
+	m.mu.Lock()
+	if len(m.events) >= m.size {
+		copy(m.events, m.events[1:])
+		m.events = m.events[:len(m.events)-1]
+	}
+	m.events = append(m.events, replayEvent{topic, e})
+	m.mu.Unlock()
+}
+
+func (m *memoryReplay) Since(id string, topics ...string) []Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if m.events[i].ID != id {
+			continue
+		}
+		events := []Event{}
+		for _, e := range m.events[i+1:] {
+			if len(topics) == 0 || inTopics(e.topic, topics) {
+				events = append(events, e.Event)
+			}
+		}
+		return events
+	}
+	return nil
+}
+
+func inTopics(topic string, topics []string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
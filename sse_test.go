@@ -0,0 +1,105 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer written by a stream goroutine, read by the test
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newEventStream(w *bufio.Writer, lastID string) *EventStream {
+	return &EventStream{w: w, codec: stdJSON{}, lastID: lastID, done: make(chan struct{})}
+}
+
+func TestSSEFormat(t *testing.T) {
+	cases := []struct {
+		e    Event
+		want string
+	}{
+		{Event{Data: "x"}, "data: x\n\n"},
+		{Event{Data: "a\nb\r\nc\rd"}, "data: a\ndata: b\ndata: c\ndata: d\n\n"},
+		{Event{Data: "end\n"}, "data: end\ndata: \n\n"},
+		{Event{Data: ""}, "data: \n\n"},
+		{Event{Data: []byte("raw")}, "data: raw\n\n"},
+		{Event{Data: map[string]int{"n": 1}}, "data: {\"n\":1}\n\n"},
+		{Event{ID: "1\n2", Event: "up\r\ndate", Data: "x"}, "id: 1 2\nevent: up date\ndata: x\n\n"},
+		{Event{Retry: 1500 * time.Millisecond}, "retry: 1500\n\n"},
+		{Event{ID: "7", Retry: 2 * time.Second, Data: "x"}, "id: 7\nretry: 2000\ndata: x\n\n"},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		s := newEventStream(bufio.NewWriter(&buf), "")
+		if err := s.Send(tc.e); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("Send(%+v): got %q, want %q", tc.e, buf.String(), tc.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	s := newEventStream(bufio.NewWriter(&buf), "")
+	s.Comment("a\nb")
+	if want := ":a\n:b\n\n"; buf.String() != want {
+		t.Errorf("Comment: got %q, want %q", buf.String(), want)
+	}
+	s.Close()
+	if err := s.Send(Event{Data: "x"}); err != ErrStreamClosed {
+		t.Errorf("Send after Close: %v", err)
+	}
+}
+
+func TestReplayBuffer(t *testing.T) {
+	r := NewReplayBuffer(3)
+	r.Add("a", Event{ID: "1"})
+	r.Add("a", Event{Data: "no id"})
+	r.Add("b", Event{ID: "2"})
+	r.Add("a", Event{ID: "3"})
+	r.Add("b", Event{ID: "4"})
+
+	ids := func(events []Event) (s []string) {
+		for _, e := range events {
+			s = append(s, e.ID)
+		}
+		return
+	}
+	cases := []struct {
+		id     string
+		topics []string
+		want   []string
+		isNil  bool
+	}{
+		{"1", nil, nil, true}, // evicted
+		{"9", nil, nil, true},
+		{"2", nil, []string{"3", "4"}, false},
+		{"2", []string{"a"}, []string{"3"}, false},
+		{"2", []string{"a", "b"}, []string{"3", "4"}, false},
+		{"4", nil, nil, false},
+	}
+	for _, tc := range cases {
+		got := r.Since(tc.id, tc.topics...)
+		if (got == nil) != tc.isNil || fmt.Sprint(ids(got)) != fmt.Sprint(tc.want) {
+			t.Errorf("Since(%s, %v): got %v (nil %v), want %v (nil %v)", tc.id, tc.topics, ids(got), got == nil, tc.want, tc.isNil)
+		}
+	}
+}
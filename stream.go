@@ -0,0 +1,165 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"runtime/debug"
+)
+
+// streamFlushEvery items of StreamJSON written between flushes.
+const streamFlushEvery = 64
+
+// Stream 以 chunked 方式发送响应, 不占用内存
+// fn starts in its own goroutine right away and runs while the handler chain
+// goes on and after it returned, so it must not use c: read params, headers
+// and services before. Returning an error or failing a write (the client
+// went away) ends the response. A panic in fn is logged and ends the
+// response, Recover does not see it. Stream bodies get no ETag.
+//
+//  c.Set(web.HeaderContentType, "text/csv")
+//  rows := db.Export(c.Params("table"))
+//  c.Stream(func(w *bufio.Writer) error {
+//  	for rows.Next() {
+//  		if _, err := w.WriteString(rows.CSV()); err != nil {
+//  			return err
+//  		}
+//  	}
+//  	return rows.Close()
+//  })
+func (c *Ctx) Stream(fn func(w *bufio.Writer) error) {
+	debug := c.Core != nil && c.Debug
+	c.streamWriter("Stream", func(w *bufio.Writer) {
+		err := fn(w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil && debug {
+			log.Printf("Stream: %v", err)
+		}
+	})
+}
+
+// streamWriter set fn as the body stream writer, fasthttp runs it in a new
+// goroutine where a panic would kill the process: it is logged instead.
+func (c *Ctx) streamWriter(name string, fn func(w *bufio.Writer)) {
+	method, path := c.method, c.path
+	c.Response.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			if r := recover(); r != nil {
+				err := &PanicError{Value: r, Stack: debug.Stack()}
+				log.Printf("%s %s %s: %v\n%s", method, path, name, err, err.Stack)
+			}
+		}()
+		fn(w)
+	})
+}
+
+// SendStream 发送 reader 的内容
+// size is the Content-Length, -1 (default) sends chunked,
+// r is closed after sending if it is an io.Closer.
+//
+//  f, _ := os.Open("export.csv")
+//  st, _ := f.Stat()
+//  c.SendStream(f, int(st.Size()))
+func (c *Ctx) SendStream(r io.Reader, size ...int) {
+	n := -1
+	if len(size) > 0 {
+		n = size[0]
+	}
+	c.Response.SetBodyStream(r, n)
+}
+
+// StreamJSON 流式发送 json 数组 [item, item ...]
+// items is a channel or an iterator func() (item interface{}, ok bool),
+// each item is encoded by the json codec. A channel is read until it is
+// closed or the client went away, so producers should also watch their
+// own context. Items are flushed every 64 items and whenever the channel is empty.
+//
+//  ch := make(chan *User)
+//  go db.EachUser(ctx, ch)
+//  return c.StreamJSON(ch)
+func (c *Ctx) StreamJSON(items interface{}) error {
+	return c.streamItems(items, MIMEApplicationJSON, "[", ",", "]")
+}
+
+// StreamNDJSON 流式发送 newline delimited json, one item per line
+// items as StreamJSON.
+func (c *Ctx) StreamNDJSON(items interface{}) error {
+	return c.streamItems(items, MIMEApplicationNDJSON, "", "", "")
+}
+
+// hasBody reports a body was sent, stream bodies are not read.
+func (c *Ctx) hasBody() bool {
+	return c.Response.IsBodyStream() || len(c.Response.Body()) > 0
+}
+
+func (c *Ctx) streamItems(items interface{}, ctype, open, sep, end string) error {
+	next, err := iterate(items)
+	if err != nil {
+		return err
+	}
+	codec := c.jsonCodec()
+	c.Response.Header.SetContentType(ctype)
+	c.Stream(func(w *bufio.Writer) error {
+		w.WriteString(open)
+		for n := 0; ; n++ {
+			item, ok, err := next(w.Flush)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			raw, err := codec.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				w.WriteString(sep)
+			}
+			w.Write(raw)
+			if ctype == MIMEApplicationNDJSON {
+				w.WriteByte('\n')
+			}
+			if n%streamFlushEvery == streamFlushEvery-1 {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := w.WriteString(end)
+		return err
+	})
+	return nil
+}
+
+// iterate items of a channel or an iterator func,
+// wait is called before blocking on an empty channel.
+func iterate(items interface{}) (func(wait func() error) (interface{}, bool, error), error) {
+	if fn, ok := items.(func() (interface{}, bool)); ok {
+		return func(func() error) (interface{}, bool, error) {
+			item, ok := fn()
+			return item, ok, nil
+		}, nil
+	}
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, fmt.Errorf("stream: %T is not a channel or func() (interface{}, bool)", items)
+	}
+	return func(wait func() error) (interface{}, bool, error) {
+		x, ok := v.TryRecv()
+		if !ok && !x.IsValid() { // empty, flush what we have
+			if err := wait(); err != nil {
+				return nil, false, err
+			}
+			x, ok = v.Recv()
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		return x.Interface(), true, nil
+	}, nil
+}
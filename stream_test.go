@@ -0,0 +1,56 @@
+package web
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	app := New(&Options{ETag: true})
+	app.Get("/csv", func(c *Ctx) {
+		c.Stream(func(w *bufio.Writer) error {
+			_, err := w.WriteString("a,b\n")
+			return err
+		})
+	})
+	app.Get("/panic", func(c *Ctx) {
+		c.Stream(func(w *bufio.Writer) error {
+			w.WriteString("partial")
+			panic("export failed")
+		})
+	})
+	app.Get("/sse", func(c *Ctx) {
+		c.SSE(func(s *EventStream) {
+			s.Send(Event{Data: "x"})
+			panic("sse failed")
+		}, SSEConfig{Heartbeat: -1})
+	})
+	app.Get("/nd", func(c *Ctx) {
+		ch := make(chan int, 2)
+		ch <- 1
+		ch <- 2
+		close(ch)
+		c.StreamNDJSON(ch)
+	})
+	app.Build()
+
+	cases := []struct{ uri, body string }{
+		{"/csv", "a,b\n"},
+		{"/panic", "partial"},
+		{"/sse", ":\n\ndata: x\n\n"},
+		{"/nd", "1\n2\n"},
+	}
+	for _, tc := range cases {
+		resp := serve(app, MethodGet, tc.uri)
+		if body := string(resp.Body()); body != tc.body {
+			t.Errorf("GET %s: got %q, want %q", tc.uri, body, tc.body)
+		}
+		if len(resp.Header.Peek(HeaderETag)) > 0 {
+			t.Errorf("GET %s: stream got an ETag", tc.uri)
+		}
+	}
+	if ct := string(serve(app, MethodGet, "/nd").Header.ContentType()); !strings.HasPrefix(ct, MIMEApplicationNDJSON) {
+		t.Errorf("ndjson content type %q", ct)
+	}
+}
@@ -107,8 +107,8 @@ func (s *HandlebarsEngine) Layout(layoutFile string) *HandlebarsEngine {
 
 // AddFunc adds the function to the template's function map.
 // It is legal to overwrite elements of the default actions:
-// - url func(routeName string, args ...string) string
-// - urlpath func(routeName string, args ...string) string
+// - url func(routeName string, options *raymond.Options) string, params by hash: {{url "user" id=1}}
+// - urlpath func(routeName string, options *raymond.Options) string, same as url
 // - render func(fullPartialName string) (raymond.HTML, error).
 func (s *HandlebarsEngine) AddFunc(funcName string, funcBody interface{}) {
 	s.rmu.Lock()
@@ -0,0 +1,443 @@
+package web
+
+import (
+	"sort"
+	"strings"
+)
+
+// tree 按 method 划分的前缀树
+// every registered route is inserted into the tree of its method,
+// "*" holds ALL routes and USE middleware.
+type tree map[string]*node
+
+// node radix tree node
+type node struct {
+	path     string      // static label of this edge
+	indices  string      // first byte of every static child
+	children []*node     // static children
+	params   []*node     // children matching one ":param" segment, one per constraint
+	cons     *constraint // constraint of a param node, nil matches any segment
+	wild     *node       // child matching "*"
+	leaves   []*leaf     // routes ending at this node
+	prefixes []*Route    // middleware routes matching every path with this prefix
+}
+
+// leaf one variant of a route, optional params expand to several variants.
+type leaf struct {
+	route  *Route
+	skip   uint64 // params absent in this variant
+	rank   uint64 // variant preference, higher wins
+	dedupe bool   // the route can be reached more than once
+}
+
+// routeMatch matched route and its param values in Ctx.pvalues
+type routeMatch struct {
+	route      *Route
+	rank       uint64
+	start, end int
+}
+
+const (
+	tokenStatic = iota
+	tokenParam
+	tokenWild
+)
+
+type routeToken struct {
+	kind     int
+	text     string
+	optional bool
+	cons     *constraint
+}
+
+// parseRoute split path to tokens the same way getRegex does.
+func parseRoute(path string) (tokens []routeToken) {
+	segments := splitPath(path)
+	for i := range segments {
+		s := segments[i]
+		if s == "" {
+			continue
+		}
+		switch s[0] {
+		case ':':
+			_, cons, optional := paramSegment(s)
+			tokens = append(tokens, routeToken{kind: tokenParam, optional: optional, cons: parseConstraint(cons)})
+		case '*':
+			tokens = append(tokens, routeToken{kind: tokenWild})
+		default:
+			tokens = append(tokens, routeToken{kind: tokenStatic, text: s})
+		}
+	}
+	return
+}
+
+// splitPath split path by "/" outside of constraints,
+// "/user/:day<regex(\\d+/\\d+)>" has two segments, parseConstraint rejects the "/".
+func splitPath(path string) (segments []string) {
+	depth, start := 0, 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '<':
+			depth++
+		case '>':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, path[start:])
+}
+
+// paramSegment split ":name<constraint>?" to its parts.
+func paramSegment(s string) (name, cons string, optional bool) {
+	s = strings.TrimPrefix(s, ":")
+	if i := strings.IndexByte(s, '<'); i >= 0 {
+		if j := strings.LastIndexByte(s, '>'); j > i {
+			return s[:i], s[i+1 : j], strings.HasSuffix(s[j+1:], "?")
+		}
+	}
+	return strings.TrimSuffix(s, "?"), "", strings.Contains(s, "?")
+}
+
+// lowerPath lowercase path but the constraints,
+// regex constraints ignore case as the request path is lowercased too.
+func lowerPath(path string) string {
+	if !strings.Contains(path, "<") {
+		return strings.ToLower(path)
+	}
+	segments := splitPath(path)
+	for i, s := range segments {
+		if len(s) > 0 && s[0] == ':' {
+			if j := strings.IndexByte(s, '<'); j >= 0 {
+				cons := s[j:]
+				if strings.HasPrefix(cons, "<regex(") && !strings.HasPrefix(cons, "<regex((?i)") {
+					cons = "<regex((?i)" + cons[len("<regex("):]
+				}
+				segments[i] = strings.ToLower(s[:j]) + cons
+				continue
+			}
+		}
+		segments[i] = strings.ToLower(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (t tree) root(method string) *node {
+	n, ok := t[method]
+	if !ok {
+		n = new(node)
+		t[method] = n
+	}
+	return n
+}
+
+// add insert route into the tree,
+// with strict the trailing slash of r.Path is an edge of its own.
+func (t tree) add(r *Route, strict bool) {
+	if r.isMiddleware {
+		n := t.root("*")
+		if !r.isStar && !r.isSlash {
+			n = n.static(r.Path)
+		}
+		n.prefixes = append(n.prefixes, r)
+		return
+	}
+
+	root := t.root(r.Method)
+	tokens := parseRoute(r.Path)
+	var optional []int
+	for i := range tokens {
+		if tokens[i].optional {
+			optional = append(optional, i)
+		}
+	}
+	variants := uint64(1) << uint(len(optional))
+	wildMid := false
+	for i := range tokens {
+		if tokens[i].kind == tokenWild && i < len(tokens)-1 {
+			wildMid = true
+		}
+	}
+	// mask bit set == optional param present
+	for mask := uint64(0); mask < variants; mask++ {
+		l := &leaf{route: r, dedupe: variants > 1 || wildMid}
+		n := root
+		param := 0
+		opt := 0
+		for i := range tokens {
+			tk := tokens[i]
+			switch tk.kind {
+			case tokenStatic:
+				n = n.static("/" + tk.text)
+			case tokenParam, tokenWild:
+				if tk.optional {
+					bit := uint(len(optional) - 1 - opt)
+					opt++
+					if mask&(1<<bit) == 0 {
+						l.skip |= 1 << uint(param)
+						param++
+						continue
+					}
+					l.rank |= 1 << bit
+				}
+				param++
+				n = n.static("/")
+				if tk.kind == tokenParam {
+					n = n.param(tk.cons)
+				} else {
+					if n.wild == nil {
+						n.wild = new(node)
+					}
+					n = n.wild
+				}
+			}
+		}
+		if strict && strings.HasSuffix(r.Path, "/") {
+			n = n.static("/")
+		}
+		n.leaves = append(n.leaves, l)
+	}
+}
+
+// param returns the param child with the same constraint.
+func (n *node) param(cons *constraint) *node {
+	for _, child := range n.params {
+		if child.cons.String() == cons.String() {
+			return child
+		}
+	}
+	child := &node{cons: cons}
+	n.params = append(n.params, child)
+	return child
+}
+
+// static returns the node at the end of s, splitting edges on the way.
+func (n *node) static(s string) *node {
+	for len(s) > 0 {
+		i := strings.IndexByte(n.indices, s[0])
+		if i < 0 {
+			child := &node{path: s}
+			n.indices += s[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+		child := n.children[i]
+		l := 0
+		for l < len(s) && l < len(child.path) && s[l] == child.path[l] {
+			l++
+		}
+		if l < len(child.path) {
+			tail := new(node)
+			*tail = *child
+			tail.path = child.path[l:]
+			*child = node{
+				path:     child.path[:l],
+				indices:  tail.path[:1],
+				children: []*node{tail},
+			}
+		}
+		s = s[l:]
+		n = child
+	}
+	return n
+}
+
+// lookup collect every route matching method and path ordered by registration.
+func (t tree) lookup(ctx *Ctx, method, path string, strict bool) {
+	ctx.matches = ctx.matches[:0]
+	ctx.pvalues = ctx.pvalues[:0]
+	ctx.pstack = ctx.pstack[:0]
+	if n, ok := t[method]; ok {
+		n.walk(ctx, path, strict)
+	}
+	if method == MethodHead {
+		if n, ok := t[MethodGet]; ok {
+			n.walk(ctx, path, strict)
+		}
+	}
+	if n, ok := t["*"]; ok {
+		n.walk(ctx, path, strict)
+	}
+	// insertion sort, matches are few and mostly ordered.
+	m := ctx.matches
+	for i := 1; i < len(m); i++ {
+		for j := i; j > 0 && m[j].route.pos < m[j-1].route.pos; j-- {
+			m[j], m[j-1] = m[j-1], m[j]
+		}
+	}
+}
+
+// allowed returns the methods having a route for path, HEAD follows GET,
+// OPTIONS is left to the caller.
+func (t tree) allowed(path string, strict bool) (methods []string) {
+	var custom []string
+	for method, n := range t {
+		if method == "*" || method == MethodOptions || !n.match(path, strict) {
+			continue
+		}
+		if _, ok := methodINT[method]; !ok {
+			custom = append(custom, method)
+		}
+	}
+	for _, method := range []string{MethodGet, MethodHead, MethodPost, MethodPut, MethodDelete, MethodConnect, MethodTrace, MethodPatch} {
+		n, ok := t[method]
+		match := ok && n.match(path, strict)
+		if !match && method == MethodHead {
+			n, ok = t[MethodGet]
+			match = ok && n.match(path, strict)
+		}
+		if match {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(custom)
+	return append(methods, custom...)
+}
+
+// has reports whether a route of method or ALL ends at path.
+func (t tree) has(method, path string, strict bool) bool {
+	for _, m := range []string{method, "*"} {
+		if n, ok := t[m]; ok && n.match(path, strict) {
+			return true
+		}
+	}
+	if method == MethodHead {
+		return t.has(MethodGet, path, strict)
+	}
+	return false
+}
+
+// match reports whether a route ends at path, middleware not included.
+func (n *node) match(path string, strict bool) bool {
+	if len(n.leaves) > 0 && (path == "" || path == "/" && !strict) {
+		return true
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+		child := n.children[i]
+		if strings.HasPrefix(path, child.path) && child.match(path[len(child.path):], strict) {
+			return true
+		}
+	}
+	if len(n.params) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		for _, child := range n.params {
+			if end > 0 && child.cons.match(path[:end]) && child.match(path[end:], strict) {
+				return true
+			}
+		}
+	}
+	if n.wild != nil {
+		for i := len(path); i >= 0; i-- {
+			if n.wild.match(path[i:], strict) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *node) walk(ctx *Ctx, path string, strict bool) {
+	for i := range n.prefixes {
+		if r := n.prefixes[i]; r.segment && path != "" && path[0] != '/' && !strings.HasSuffix(r.Path, "/") {
+			continue
+		}
+		ctx.matches = append(ctx.matches, routeMatch{route: n.prefixes[i]})
+	}
+	if len(n.leaves) > 0 && (path == "" || path == "/" && !strict) {
+		for i := range n.leaves {
+			ctx.addMatch(n.leaves[i])
+		}
+	}
+	if len(path) == 0 {
+		return
+	}
+	if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+		child := n.children[i]
+		if strings.HasPrefix(path, child.path) {
+			child.walk(ctx, path[len(child.path):], strict)
+		}
+	}
+	if len(n.params) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			ctx.pstack = append(ctx.pstack, path[:end])
+			for _, child := range n.params {
+				if child.cons.match(path[:end]) {
+					child.walk(ctx, path[end:], strict)
+				}
+			}
+			ctx.pstack = ctx.pstack[:len(ctx.pstack)-1]
+		}
+	}
+	if n.wild != nil {
+		end := 0
+		if len(n.wild.params) == 0 && n.wild.wild == nil && len(n.wild.children) == 0 {
+			end = len(path) // nothing follows, only the greedy match counts
+		}
+		for i := len(path); i >= end; i-- {
+			ctx.pstack = append(ctx.pstack, path[:i])
+			n.wild.walk(ctx, path[i:], strict)
+			ctx.pstack = ctx.pstack[:len(ctx.pstack)-1]
+		}
+	}
+}
+
+// endpoint reports whether a route other than middleware matched.
+func (ctx *Ctx) endpoint() bool {
+	for i := range ctx.matches {
+		if !ctx.matches[i].route.isMiddleware {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRun reports whether the handler with Route.once id ran.
+func (ctx *Ctx) hasRun(id uint64) bool {
+	for _, ran := range ctx.ran {
+		if ran == id {
+			return true
+		}
+	}
+	return false
+}
+
+// addMatch push the leaf's route with its param values.
+func (ctx *Ctx) addMatch(l *leaf) {
+	start := len(ctx.pvalues)
+	captured := 0
+	for i := range l.route.Params {
+		if l.skip&(1<<uint(i)) != 0 || captured >= len(ctx.pstack) {
+			ctx.pvalues = append(ctx.pvalues, "")
+			continue
+		}
+		ctx.pvalues = append(ctx.pvalues, ctx.pstack[captured])
+		captured++
+	}
+	m := routeMatch{route: l.route, rank: l.rank, start: start, end: len(ctx.pvalues)}
+	if l.dedupe {
+		for i := range ctx.matches {
+			if ctx.matches[i].route == l.route {
+				if l.rank > ctx.matches[i].rank {
+					ctx.matches[i] = m
+				}
+				return
+			}
+		}
+	}
+	ctx.matches = append(ctx.matches, m)
+}
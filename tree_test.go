@@ -0,0 +1,213 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// matchRoute the regexp matcher of the linear route scan, kept to compare with the tree
+func matchRoute(r *Route, method, path string) (match bool, values []string) {
+	if r.isMiddleware {
+		if r.isStar || r.isSlash {
+			return true, values
+		}
+		if strings.HasPrefix(path, r.Path) {
+			return true, values
+		}
+		return false, values
+	}
+	if r.Method == method || r.Method[0] == '*' || (r.isGet && len(method) == 4 && method == "HEAD") {
+		if r.isStar {
+			return true, values
+		}
+		if r.isSlash && path == "/" {
+			return true, values
+		}
+		if r.isRegex && r.Regexp.MatchString(path) {
+			if len(r.Params) > 0 {
+				matches := r.Regexp.FindAllStringSubmatch(path, -1)
+				if len(matches) > 0 && len(matches[0]) > 1 {
+					values = matches[0][1:len(matches[0])]
+					return true, values
+				}
+				return false, values
+			}
+			return true, values
+		}
+		if len(r.Path) == len(path) && r.Path == path {
+			return true, values
+		}
+	}
+	return false, values
+}
+
+func scanMatches(c *Core, method, path string) (out []string) {
+	for _, r := range c.routes {
+		if ok, v := matchRoute(r, method, path); ok {
+			if r.isStar && !r.isMiddleware {
+				v = nil
+			}
+			out = append(out, fmt.Sprint(r.pos, v))
+		}
+	}
+	return
+}
+
+func treeMatches(c *Core, method, path string) (out []string) {
+	ctx := new(Ctx)
+	c.tree.lookup(ctx, method, path, false)
+	for _, m := range ctx.matches {
+		v := ctx.pvalues[m.start:m.end]
+		if len(v) == 0 || (m.route.isStar && !m.route.isMiddleware) {
+			v = nil
+		}
+		out = append(out, fmt.Sprint(m.route.pos, v))
+	}
+	return
+}
+
+func nop(*Ctx) {}
+
+func TestTreeMatchesScan(t *testing.T) {
+	c := New()
+	for _, r := range [][2]string{
+		{"USE", "/"}, {"USE", "/api"}, {"GET", "/api"}, {"GET", "/api/:param?"},
+		{"POST", "/api/:param"}, {"GET", "/api/check"}, {"GET", "/api/user/:id/orders/:oid"},
+		{"GET", "/api/user/:id/:x?/:y?"}, {"ALL", "/all/:a"}, {"GET", "/static/*"},
+		{"GET", "/mid/*/end"}, {"GET", "/"}, {"GET", "/:root?"}, {"USE", "/administrator"},
+		{"PUT", "/Files/:ID"}, {"GET", "/a.b/:x"},
+	} {
+		c.pushMethod(r[0], r[1], nop)
+	}
+	paths := []string{"/", "/api", "/api/x", "/api/check", "/api/check/x", "/api/user/1/orders/2",
+		"/api/user/1", "/api/user/1/a", "/api/user/1/a/b", "/api/user/1/a/b/c", "/all/z", "/all",
+		"/static/a/b", "/static", "/mid/a/b/end", "/mid/end", "/mid/x/end", "/administrator/x",
+		"/apix", "/files/abc", "/foo", "/a.b/c"}
+	for _, m := range []string{MethodGet, MethodPost, MethodPut, MethodHead, MethodDelete} {
+		for _, p := range paths {
+			want, got := scanMatches(c, m, p), treeMatches(c, m, p)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%s %s\n scan %v\n tree %v", m, p, want, got)
+			}
+		}
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	app := New()
+	mw := func(name string) func(*Ctx) {
+		return func(c *Ctx) { c.Write(name + ";"); c.Next() }
+	}
+	app.Use(mw("root"))
+	app.Use("/api", mw("api"))
+	app.Use("/api/admin", mw("admin"))
+	app.Get("/api/user/:id", func(c *Ctx) { c.Write("user=" + c.Params("id")) })
+	app.Get("/api/opt/:name?", func(c *Ctx) { c.Write("opt=" + c.Params("name")) })
+	app.Get("/api/admin/:page", func(c *Ctx) { c.Write("page=" + c.Params("page")) })
+	app.Get("/files/*", func(c *Ctx) { c.Write("files") })
+	app.Post("/form", func(c *Ctx) { c.Write("post") })
+	app.Build()
+
+	cases := []struct {
+		method, uri string
+		code        int
+		body        string
+	}{
+		{MethodGet, "/api/user/7", 200, "root;api;user=7"},
+		{MethodGet, "/apix", 200, "root;api;"}, // middleware matches by prefix
+		{MethodGet, "/api/user", 200, "root;api;"},
+		{MethodGet, "/api/opt", 200, "root;api;opt="},
+		{MethodGet, "/api/opt/x", 200, "root;api;opt=x"},
+		{MethodGet, "/api/admin/users", 200, "root;api;admin;page=users"},
+		{MethodGet, "/files", 200, "root;"},
+		{MethodGet, "/files/a/b.txt", 200, "root;files"},
+		{MethodHead, "/api/user/7", 200, ""},
+		{MethodGet, "/form", 405, "Method Not Allowed"},
+	}
+	for _, tc := range cases {
+		resp := serve(app, tc.method, tc.uri)
+		body := string(resp.Body())
+		if resp.StatusCode() != tc.code || !strings.HasPrefix(body, tc.body) {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.uri, resp.StatusCode(), body, tc.code, tc.body)
+		}
+	}
+}
+
+func TestRouterHeadFallback(t *testing.T) {
+	c := New()
+	c.pushMethod(MethodGet, "/page", nop)
+	ctx := new(Ctx)
+	c.tree.lookup(ctx, MethodHead, "/page", false)
+	if len(ctx.matches) != 1 || ctx.matches[0].route.Method != MethodGet {
+		t.Fatalf("HEAD does not fall back to GET: %v", ctx.matches)
+	}
+	c.pushMethod(MethodHead, "/page", nop)
+	ctx = new(Ctx)
+	c.tree.lookup(ctx, MethodHead, "/page", false)
+	if len(ctx.matches) == 0 || ctx.matches[0].route.Method != MethodGet {
+		t.Fatalf("routes are not in registration order: %v", ctx.matches)
+	}
+}
+
+func TestTreeZeroAlloc(t *testing.T) {
+	c, paths := benchCore()
+	ctx := new(Ctx)
+	for _, p := range paths {
+		c.tree.lookup(ctx, MethodGet, p, false)
+	}
+	for _, p := range paths[:4] {
+		n := testing.AllocsPerRun(100, func() { c.tree.lookup(ctx, MethodGet, p, false) })
+		if n != 0 {
+			t.Errorf("GET %s: %v allocs", p, n)
+		}
+	}
+}
+
+// benchCore 55 controllers of 11 routes, 605 routes
+func benchCore() (*Core, []string) {
+	c := New()
+	var paths []string
+	for i := 0; i < 55; i++ {
+		c.pushMethod("USE", fmt.Sprintf("/ctl%d", i), nop)
+		for _, v := range []string{"list", "detail/:id", "edit/:id?", "items/:id/sub/:sid", "files/*"} {
+			c.pushMethod(MethodGet, fmt.Sprintf("/ctl%d/%s", i, v), nop)
+			c.pushMethod(MethodPost, fmt.Sprintf("/ctl%d/%s", i, v), nop)
+		}
+		paths = append(paths, fmt.Sprintf("/ctl%d/items/12/sub/34", i), fmt.Sprintf("/ctl%d/list", i))
+	}
+	return c, paths
+}
+
+func BenchmarkRouterScan(b *testing.B) {
+	c, paths := benchCore()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := paths[i%len(paths)]
+		for _, r := range c.routes {
+			matchRoute(r, MethodGet, p)
+		}
+	}
+}
+
+func BenchmarkRouterTree(b *testing.B) {
+	c, paths := benchCore()
+	ctx := new(Ctx)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.tree.lookup(ctx, MethodGet, paths[i%len(paths)], false)
+	}
+}
+
+func BenchmarkRouterServe(b *testing.B) {
+	c, paths := benchCore()
+	c.Build()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		serve(c, MethodGet, paths[i%len(paths)])
+	}
+}
@@ -65,14 +65,15 @@ func getParams(path string) (params []string) {
 	if len(path) < 1 {
 		return
 	}
-	segments := strings.Split(path, "/")
-	replacer := strings.NewReplacer(":", "", "?", "")
+	segments := splitPath(path)
 	for i := range segments {
 		s := segments[i]
 		if s == "" {
 			continue
 		} else if s[0] == ':' {
-			params = append(params, replacer.Replace(s))
+			name, _, _ := paramSegment(s)
+			params = append(params, name)
+			continue
 		}
 		if strings.Contains(s, "*") {
 			params = append(params, "*")
@@ -83,14 +84,14 @@ func getParams(path string) (params []string) {
 
 func getRegex(path string) (*regexp.Regexp, error) {
 	pattern := "^"
-	segments := strings.Split(path, "/")
+	segments := splitPath(path)
 	for i := range segments {
 		s := segments[i]
 		if s == "" {
 			continue
 		}
 		if s[0] == ':' {
-			if strings.Contains(s, "?") {
+			if _, _, optional := paramSegment(s); optional {
 				pattern += "(?:/([^/]+?))?"
 			} else {
 				pattern += "/(?:([^/]+?))"
@@ -234,6 +235,20 @@ func fixURI(pre, src, tag string) string {
 	return uri
 }
 
+// joinPath join group prefix and path, always starts with slash.
+func joinPath(prefix, p string) string {
+	return path.Join("/", prefix, p)
+}
+
+// joinRoute joinPath keeping the trailing slash of p, StrictRouting tells /foo/ from /foo.
+func joinRoute(prefix, p string) string {
+	joined := joinPath(prefix, p)
+	if len(p) > 1 && strings.HasSuffix(p, "/") && joined != "/" {
+		joined += "/"
+	}
+	return joined
+}
+
 // 名称替换
 func newSafeMap() *safeMap {
 	return &safeMap{l: new(sync.RWMutex), m: make(map[string]string)}
@@ -342,10 +357,19 @@ const (
 	MIMEApplicationJavaScript = "application/javascript"
 	MIMEApplicationXML        = "application/xml"
 	MIMEApplicationForm       = "application/x-www-form-urlencoded"
+	MIMEApplicationMsgPack    = "application/msgpack"
+	MIMEApplicationCBOR       = "application/cbor"
+	MIMEApplicationYAML       = "application/yaml"
+	MIMEApplicationNDJSON     = "application/x-ndjson"
 
 	MIMEMultipartForm = "multipart/form-data"
 
 	MIMEOctetStream = "application/octet-stream"
+
+	MIMETextHTMLCharsetUTF8  = "text/html; charset=utf-8"
+	MIMETextPlainCharsetUTF8 = "text/plain; charset=utf-8"
+
+	MIMETextEventStream = "text/event-stream"
 )
 
 // MIME types were copied from nginx/mime.types.
@@ -316,8 +316,8 @@ func (s *HTMLEngine) AddLayoutFunc(funcName string, funcBody interface{}) *HTMLE
 
 // AddFunc adds the function to the template's function map.
 // It is legal to overwrite elements of the default actions:
-// - url func(routeName string, args ...string) string
-// - urlpath func(routeName string, args ...string) string
+// - url func(routeName string, args ...interface{}) (string, error), see Core.URL
+// - urlpath func(routeName string, args ...interface{}) (string, error), same as url
 // - render func(fullPartialName string) (template.HTML, error).
 // - tr func(lang, key string, args ...interface{}) string
 func (s *HTMLEngine) AddFunc(funcName string, funcBody interface{}) {
@@ -89,9 +89,17 @@ func New(handler func(*Conn), config ...Config) func(*web.Ctx) {
 		c.RequestCtx.Request.Header.VisitAllCookie(func(key, value []byte) {
 			conn.cookies[string(key)] = string(value)
 		})
+		// c is released before the hijacked handler runs
+		core := c.Core
 		if err := upgrader.Upgrade(c.RequestCtx, func(fconn *websocket.Conn) {
 			conn.Conn = fconn
 			defer releaseConn(conn)
+			// Shutdown tells the client to reconnect later
+			untrack := core.TrackHijacked(func() {
+				fconn.WriteControl(CloseMessage, FormatCloseMessage(CloseServiceRestart, "server restart"), time.Now().Add(time.Second))
+				fconn.Close()
+			})
+			defer untrack()
 			handler(conn)
 		}); err != nil { // Upgrading required
 			c.Next(web.NewError(426))
@@ -0,0 +1,317 @@
+// Package webtest 测试辅助, 通过 Core.Test 执行请求并链式断言
+//
+//  func TestUser(t *testing.T) {
+//  	app := web.New()
+//  	app.Use(new(Handler))
+//  	webtest.New(t, app).Get("/api/user/1").Expect().
+//  		Status(200).
+//  		Header("Content-Type", "application/json").
+//  		JSON(map[string]interface{}{"id": 1})
+//  }
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xs23933/web"
+)
+
+// Tester 绑定测试与 app
+type Tester struct {
+	t     testing.TB
+	app   *web.Core
+	views *recorder
+}
+
+// New builds app once and records the templates it renders.
+func New(t testing.TB, app *web.Core) *Tester {
+	t.Helper()
+	if app.Server == nil {
+		if err := app.Build(); err != nil {
+			t.Fatalf("webtest: build: %v", err)
+		}
+	}
+	s := &Tester{t: t, app: app}
+	if rec, ok := app.ViewEngine.(*recorder); ok {
+		s.views = rec
+	} else if app.ViewEngine != nil {
+		s.views = &recorder{ViewEngine: app.ViewEngine}
+		app.RegView(s.views)
+	}
+	return s
+}
+
+// Get GET request
+func (s *Tester) Get(path string) *Request { return s.Request(web.MethodGet, path, nil) }
+
+// Head HEAD request
+func (s *Tester) Head(path string) *Request { return s.Request(web.MethodHead, path, nil) }
+
+// Delete DELETE request
+func (s *Tester) Delete(path string) *Request { return s.Request(web.MethodDelete, path, nil) }
+
+// Post POST request
+func (s *Tester) Post(path string) *Request { return s.Request(web.MethodPost, path, nil) }
+
+// Put PUT request
+func (s *Tester) Put(path string) *Request { return s.Request(web.MethodPut, path, nil) }
+
+// Patch PATCH request
+func (s *Tester) Patch(path string) *Request { return s.Request(web.MethodPatch, path, nil) }
+
+// Request any method, body can be nil.
+func (s *Tester) Request(method, path string, body io.Reader) *Request {
+	return &Request{
+		tester:  s,
+		Request: httptest.NewRequest(method, path, body),
+		query:   url.Values{},
+	}
+}
+
+// Request 待发送的请求
+type Request struct {
+	*http.Request
+	tester  *Tester
+	query   url.Values
+	timeout time.Duration
+}
+
+// Header set a request header.
+func (r *Request) Header(k, v string) *Request {
+	r.Request.Header.Set(k, v)
+	return r
+}
+
+// Query add a query argument.
+func (r *Request) Query(k, v string) *Request {
+	r.query.Add(k, v)
+	return r
+}
+
+// Body raw body with content type.
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.Request.ContentLength = int64(len(body))
+	r.Request.Header.Set(web.HeaderContentType, contentType)
+	return r
+}
+
+// JSON json encoded body.
+func (r *Request) JSON(v interface{}) *Request {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		r.tester.t.Fatalf("webtest: encode json: %v", err)
+	}
+	return r.Body(web.MIMEApplicationJSON, raw)
+}
+
+// Form url encoded form body.
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body(web.MIMEApplicationForm, []byte(values.Encode()))
+}
+
+// Timeout passed to Core.Test, -1 waits forever.
+func (r *Request) Timeout(d time.Duration) *Request {
+	r.timeout = d
+	return r
+}
+
+// Expect send the request, fails the test on transport errors.
+func (r *Request) Expect() *Response {
+	t := r.tester.t
+	t.Helper()
+	if len(r.query) > 0 {
+		q := r.URL.Query()
+		for k, vs := range r.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		r.URL.RawQuery = q.Encode()
+		r.RequestURI = r.URL.RequestURI()
+	}
+
+	if r.tester.views != nil {
+		r.tester.views.reset()
+	}
+	var timeout []time.Duration
+	if r.timeout != 0 {
+		timeout = append(timeout, r.timeout)
+	}
+	resp, err := r.tester.app.Test(r.Request, timeout...)
+	if err != nil {
+		t.Fatalf("webtest: %s %s: %v", r.Method, r.URL, err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	res := &Response{Response: resp, t: t, Body: body}
+	if r.tester.views != nil {
+		res.Rendered = r.tester.views.list()
+	}
+	return res
+}
+
+// Response 响应及断言
+type Response struct {
+	*http.Response
+	t        testing.TB
+	Body     []byte
+	Rendered []Render // templates rendered while handling the request
+}
+
+// Status assert the status code.
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	if r.StatusCode != code {
+		r.t.Errorf("status: got %d, want %d\n%s", r.StatusCode, code, r.Body)
+	}
+	return r
+}
+
+// Header assert a response header, Content-Type ignores parameters unless given.
+func (r *Response) Header(k, v string) *Response {
+	r.t.Helper()
+	got := r.Response.Header.Get(k)
+	if strings.EqualFold(k, web.HeaderContentType) && !strings.Contains(v, ";") {
+		got = strings.TrimSpace(strings.Split(got, ";")[0])
+	}
+	if got != v {
+		r.t.Errorf("header %s: got %q, want %q", k, got, v)
+	}
+	return r
+}
+
+// HeaderExists assert the response header is set.
+func (r *Response) HeaderExists(k string) *Response {
+	r.t.Helper()
+	if _, ok := r.Response.Header[http.CanonicalHeaderKey(k)]; !ok {
+		r.t.Errorf("header %s: missing", k)
+	}
+	return r
+}
+
+// BodyEqual assert the whole body.
+func (r *Response) BodyEqual(s string) *Response {
+	r.t.Helper()
+	if string(r.Body) != s {
+		r.t.Errorf("body: got %q, want %q", r.Body, s)
+	}
+	return r
+}
+
+// BodyContains assert the body contains s.
+func (r *Response) BodyContains(s string) *Response {
+	r.t.Helper()
+	if !strings.Contains(string(r.Body), s) {
+		r.t.Errorf("body: %q does not contain %q", r.Body, s)
+	}
+	return r
+}
+
+// JSON assert the body is the json of want, compared after decoding
+// so key order and number types do not matter.
+func (r *Response) JSON(want interface{}) *Response {
+	r.t.Helper()
+	var got interface{}
+	if err := json.Unmarshal(r.Body, &got); err != nil {
+		r.t.Errorf("json: %v\n%s", err, r.Body)
+		return r
+	}
+	raw, err := json.Marshal(want)
+	if err != nil {
+		r.t.Fatalf("webtest: encode json: %v", err)
+	}
+	var exp interface{}
+	json.Unmarshal(raw, &exp)
+	if !reflect.DeepEqual(got, exp) {
+		r.t.Errorf("json: got %s, want %s", r.Body, raw)
+	}
+	return r
+}
+
+// Decode unmarshal the json body into out.
+func (r *Response) Decode(out interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body, out); err != nil {
+		r.t.Errorf("json: %v\n%s", err, r.Body)
+	}
+	return r
+}
+
+// Template assert the template was rendered.
+func (r *Response) Template(name string) *Response {
+	r.t.Helper()
+	for _, v := range r.Rendered {
+		if v.Name == name {
+			return r
+		}
+	}
+	names := make([]string, 0, len(r.Rendered))
+	for _, v := range r.Rendered {
+		names = append(names, v.Name)
+	}
+	r.t.Errorf("template %q not rendered, rendered: %v", name, names)
+	return r
+}
+
+// TemplateVar assert a binding of the last rendered template,
+// the binding must be a map[string]interface{} (Ctx.Vars).
+func (r *Response) TemplateVar(k string, want interface{}) *Response {
+	r.t.Helper()
+	if len(r.Rendered) == 0 {
+		r.t.Errorf("template var %s: no template rendered", k)
+		return r
+	}
+	bind, ok := r.Rendered[len(r.Rendered)-1].Binding.(map[string]interface{})
+	if !ok {
+		r.t.Errorf("template var %s: binding is %T", k, r.Rendered[len(r.Rendered)-1].Binding)
+		return r
+	}
+	if got, ok := bind[k]; !ok || !reflect.DeepEqual(got, want) {
+		r.t.Errorf("template var %s: got %#v, want %#v", k, got, want)
+	}
+	return r
+}
+
+// Render 一次模版渲染
+type Render struct {
+	Name    string
+	Layout  string
+	Binding interface{}
+}
+
+// recorder wraps the app's ViewEngine and records executions.
+type recorder struct {
+	web.ViewEngine
+	mu      sync.Mutex
+	renders []Render
+}
+
+func (v *recorder) ExecuteWriter(w io.Writer, name, layout string, bind interface{}) error {
+	v.mu.Lock()
+	v.renders = append(v.renders, Render{Name: name, Layout: layout, Binding: bind})
+	v.mu.Unlock()
+	return v.ViewEngine.ExecuteWriter(w, name, layout, bind)
+}
+
+func (v *recorder) reset() {
+	v.mu.Lock()
+	v.renders = nil
+	v.mu.Unlock()
+}
+
+func (v *recorder) list() []Render {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]Render(nil), v.renders...)
+}
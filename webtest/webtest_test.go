@@ -0,0 +1,140 @@
+package webtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xs23933/web"
+)
+
+// recordT records the failures of an assertion instead of failing the test
+type recordT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordT) Helper() {}
+
+func (t *recordT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+// Fatalf ends the goroutine like testing.T, run fatal checks in their own goroutine
+func (t *recordT) Fatalf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+	runtime.Goexit()
+}
+
+func newApp(t *testing.T) *web.Core {
+	dir, err := ioutil.TempDir("", "webtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "user.html"), []byte("<p>{{.name}}</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := web.New(&web.Options{ViewEngine: web.HTML(dir, ".html")})
+	app.Get("/text", func(c *web.Ctx) {
+		c.Set("X-Trace", "1")
+		c.Send("hello " + c.Query("name"))
+	})
+	app.Get("/json", func(c *web.Ctx) {
+		c.JSON(map[string]interface{}{"id": 1, "tags": []string{"a"}})
+	})
+	app.Post("/echo", func(c *web.Ctx) {
+		c.Response.Header.SetContentType(c.Get(web.HeaderContentType))
+		c.Send(c.Body())
+	})
+	app.Get("/user", func(c *web.Ctx) {
+		c.Vars("name", "joe")
+		c.View("user.html")
+	})
+	app.Get("/slow", func(c *web.Ctx) {
+		time.Sleep(100 * time.Millisecond)
+		c.Send("slow")
+	})
+	return app
+}
+
+func TestAssertions(t *testing.T) {
+	s := New(t, newApp(t))
+	s.Get("/text").Query("name", "joe").Expect().
+		Status(200).
+		Header("Content-Type", "text/plain").
+		HeaderExists("X-Trace").
+		BodyEqual("hello joe").
+		BodyContains("joe")
+	s.Get("/json").Expect().
+		Header(web.HeaderContentType, web.MIMEApplicationJSON).
+		JSON(map[string]interface{}{"tags": []string{"a"}, "id": 1})
+	var out struct{ ID int }
+	s.Get("/json").Expect().Decode(&out)
+	if out.ID != 1 {
+		t.Errorf("decode: %+v", out)
+	}
+	s.Post("/echo").JSON(map[string]int{"a": 1}).Expect().JSON(map[string]int{"a": 1})
+	s.Post("/echo").Form(url.Values{"a": {"1"}}).Expect().
+		Header(web.HeaderContentType, web.MIMEApplicationForm).
+		BodyEqual("a=1")
+	s.Get("/user").Expect().
+		Status(200).
+		BodyEqual("<p>joe</p>").
+		Template("user.html").
+		TemplateVar("name", "joe")
+	// renders are reset between requests
+	if res := s.Get("/text").Expect(); len(res.Rendered) != 0 {
+		t.Errorf("rendered %v", res.Rendered)
+	}
+}
+
+func TestAssertionFailures(t *testing.T) {
+	rt := &recordT{TB: t}
+	s := New(rt, newApp(t))
+	s.Get("/text").Expect().
+		Status(201).
+		Header("Content-Type", "text/html").
+		HeaderExists("X-Missing").
+		BodyEqual("bye").
+		BodyContains("bye").
+		JSON(1).
+		Decode(new(int)).
+		Template("user.html").
+		TemplateVar("name", "joe")
+	s.Get("/user").Expect().
+		Template("other.html").
+		TemplateVar("name", "ann").
+		TemplateVar("missing", 1)
+	want := []string{"status", "header Content-Type", "header X-Missing", "body", "body", "json", "json",
+		`template "user.html"`, "template var name", `template "other.html"`, "template var name", "template var missing"}
+	if len(rt.errors) != len(want) {
+		t.Fatalf("got %d failures, want %d:\n%s", len(rt.errors), len(want), strings.Join(rt.errors, "\n"))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(rt.errors[i], prefix) {
+			t.Errorf("failure %d: %q, want prefix %q", i, rt.errors[i], prefix)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	app := newApp(t)
+	rt := &recordT{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		New(rt, app).Get("/slow").Timeout(10 * time.Millisecond).Expect()
+	}()
+	<-done
+	if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "timeout") {
+		t.Errorf("timeout: %v", rt.errors)
+	}
+	New(t, app).Get("/slow").Timeout(-1).Expect().BodyEqual("slow")
+}